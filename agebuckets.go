@@ -0,0 +1,142 @@
+package tree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/message"
+)
+
+// ageUnits are the units for ParseAgeBuckets, m is months (30 days)
+var ageUnits = map[string]time.Duration{
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"m": 30 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// ageStyles are the ANSI styles for the buckets, from the newest to the
+// oldest.
+var ageStyles = []string{"1;32", "32", "36", "33", "35", "31"}
+
+// AgeBuckets split the entries by the age of their mtime, for
+// Options.AgeBuckets. The entries older than the last limit are in the last
+// bucket, so there's one more bucket than the limits.
+type AgeBuckets struct {
+	Limits []time.Duration // Increasing
+	Labels []string        // For each bucket, like "1d-1w"
+	Now    time.Time
+}
+
+// ParseAgeBuckets returns the AgeBuckets for the limits, like "1d,1w,1m,1y".
+// The units are h, d, w, m (30 days) and y (365 days).
+func ParseAgeBuckets(s string, now time.Time) (*AgeBuckets, error) {
+	ab := &AgeBuckets{Now: now}
+	limits := strings.Split(s, ",")
+	for i, limit := range limits {
+		limit = strings.TrimSpace(limit)
+		unit := strings.TrimLeft(limit, "0123456789.")
+		dur, ok := ageUnits[unit]
+		num, err := strconv.ParseFloat(strings.TrimSuffix(limit, unit), 64)
+		if !ok || err != nil || num <= 0 {
+			return nil, fmt.Errorf("invalid age bucket %q, expected like 1d,1w,1m,1y", limit)
+		}
+		dur = time.Duration(num * float64(dur))
+		if i > 0 && dur <= ab.Limits[i-1] {
+			return nil, fmt.Errorf("invalid age buckets %q, they must be increasing", s)
+		}
+		ab.Limits = append(ab.Limits, dur)
+
+		if i == 0 {
+			ab.Labels = append(ab.Labels, "<"+limit)
+		} else {
+			ab.Labels = append(ab.Labels, strings.TrimSpace(limits[i-1])+"-"+limit)
+		}
+	}
+	ab.Labels = append(ab.Labels, ">"+strings.TrimSpace(limits[len(limits)-1]))
+	return ab, nil
+}
+
+// Bucket returns the index of the bucket for the mtime.
+func (ab *AgeBuckets) Bucket(mtime time.Time) int {
+	age := ab.Now.Sub(mtime)
+	for i, limit := range ab.Limits {
+		if age < limit {
+			return i
+		}
+	}
+	return len(ab.Limits)
+}
+
+// style returns the ANSI style for the bucket, the oldest is always red.
+func (ab *AgeBuckets) style(bucket int) string {
+	if len(ab.Limits) == 0 {
+		return ageStyles[0]
+	}
+	return ageStyles[bucket*(len(ageStyles)-1)/len(ab.Limits)]
+}
+
+// Color returns s in the ANSI style for the bucket.
+func (ab *AgeBuckets) Color(bucket int, s string) string {
+	return fmt.Sprintf("%s[%sm%s%s[%dm", Escape, ab.style(bucket), s, Escape, Reset)
+}
+
+// AgeCount is the files in an age bucket, for the report.
+type AgeCount struct {
+	Label string `json:"label"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"size"`
+}
+
+// AgeCounts are the counts for each bucket, it's a pointer in the Summary
+// so that stays comparable.
+type AgeCounts []AgeCount
+
+// addAge counts the file in its age bucket.
+func (sum *Summary) addAge(ab *AgeBuckets, node *Node) {
+	if node.err != nil || node.IsDir() {
+		return
+	}
+	if sum.Ages == nil {
+		ages := make(AgeCounts, len(ab.Labels))
+		for i, label := range ab.Labels {
+			ages[i].Label = label
+		}
+		sum.Ages = &ages
+	}
+	age := &(*sum.Ages)[ab.Bucket(node.ModTime())]
+	age.Files++
+	age.Bytes += node.Size()
+}
+
+// ageReportText returns the legend of the age buckets, with their counts.
+func ageReportText(opts *Options, p *message.Printer, sum *Summary) string {
+	ab := opts.AgeBuckets
+	width := 0
+	for _, label := range ab.Labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+	footer := ""
+	for i, label := range ab.Labels {
+		var age AgeCount
+		if sum.Ages != nil {
+			age = (*sum.Ages)[i]
+		}
+		label = fmt.Sprintf("%-*s", width, label)
+		if opts.Colorize {
+			label = ab.Color(i, label)
+		}
+		if opts.UnitSize {
+			footer += p.Sprintf("\n%s %d files, %s size", label, age.Files,
+				strings.TrimSpace(FormatSize(opts, age.Bytes)))
+		} else {
+			footer += p.Sprintf("\n%s %d files, %d size", label, age.Files, age.Bytes)
+		}
+	}
+	return footer
+}
@@ -0,0 +1,51 @@
+package tree
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgeBuckets(t *testing.T) {
+	now := testTime
+	if _, err := ParseAgeBuckets("1w,1d", now); err == nil {
+		t.Error("expected an error for decreasing buckets")
+	}
+	ab, err := ParseAgeBuckets("1d,1w", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := NewMapFs().
+		AddDir("root", 0755, now).
+		AddFile("root/a", []byte("a"), 0644, now.Add(-time.Hour)).
+		AddFile("root/b", []byte("bb"), 0644, now.Add(-48*time.Hour)).
+		AddFile("root/c", []byte("ccc"), 0644, now.Add(-24*time.Hour*30))
+
+	defer out.clear()
+	opts := &Options{Fs: mfs, OutFile: out, AgeBuckets: ab}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `root
+┣━ a
+┣━ b
+┗━ c
+
+0 directories, 3 files
+<1d   1 files, 1 size
+1d-1w 1 files, 2 size
+>1w   1 files, 3 size
+`
+	if sum.Ages == nil || len(*sum.Ages) != 3 || !out.equal(expected) {
+		t.Errorf("got %+v:\n%+v\nexpected:\n%+v", sum.Ages, out.str, expected)
+	}
+
+	out.clear()
+	opts.Colorize = true
+	visitMapFs(mfs, opts).Print(opts)
+	if !strings.Contains(out.str, ab.Color(2, "c")) {
+		t.Errorf("expected c colored as the oldest:\n%q", out.str)
+	}
+}
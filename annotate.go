@@ -0,0 +1,39 @@
+package tree
+
+import "sort"
+
+// Annotate sets the key to the value in the extra data of the node, which is
+// kept in the structured outputs (JSON, XML and NDJSON). It's meant to be
+// called from the hooks (VisitWrapper or Inject), for the node being visited.
+func (node *Node) Annotate(key, value string) {
+	if node.extra == nil {
+		node.extra = make(map[string]string)
+	}
+	node.extra[key] = value
+}
+
+// Annotations returns the extra data set with Annotate, or nil.
+func (node *Node) Annotations() map[string]string {
+	return node.extra
+}
+
+// xmlExtra is an annotation in the XML output
+type xmlExtra struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlAnnotations returns the annotations of the node, sorted by key.
+func xmlAnnotations(node *Node) []xmlExtra {
+	var keys []string
+	for key := range node.extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var ret []xmlExtra
+	for _, key := range keys {
+		ret = append(ret, xmlExtra{Key: key, Value: node.extra[key]})
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// asciiLetters are the transliterations for the letters without a
+// decomposition to ASCII, and the common punctuation.
+var asciiLetters = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "Th", 'ı': "i",
+	'‘': "'", '’': "'", '“': `"`, '”': `"`, '–': "-", '—': "-",
+	'…': "...", ' ': " ",
+}
+
+// asciiName returns the name with only ASCII, for Options.ASCIINames. The
+// accents are removed (é is e), some letters are transliterated (ß is ss)
+// and the rest are escaped like Go strings (\u4e16).
+func asciiName(name string) string {
+	if isASCII(name) {
+		return name
+	}
+	var buf strings.Builder
+	for _, r := range name {
+		if r < utf8.RuneSelf {
+			buf.WriteRune(r)
+			continue
+		}
+		if s, ok := asciiLetters[r]; ok {
+			buf.WriteString(s)
+			continue
+		}
+		// The letter, without the accents from the decomposition
+		base := strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			return r
+		}, norm.NFD.String(string(r)))
+		switch {
+		case base != "" && isASCII(base):
+			buf.WriteString(base)
+		case r > 0xffff:
+			fmt.Fprintf(&buf, `\U%08x`, r)
+		default:
+			fmt.Fprintf(&buf, `\u%04x`, r)
+		}
+	}
+	return buf.String()
+}
+
+// isASCII returns if the string is all ASCII.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package tree
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// getAccessTime returns the last access time of the file.
+func getAccessTime(fi os.FileInfo) (time.Time, bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Atimespec.Unix()), true
+}
@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package tree
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAccessTime(t *testing.T) {
+	defer out.clear()
+	atime := func(sec int64) *syscall.Stat_t {
+		return &syscall.Stat_t{Atim: syscall.Timespec{Sec: sec}}
+	}
+	root := &file{name: "root", stat: atime(1577934245), files: []*file{
+		{name: "a", stat: atime(1577934245 + 3600)},
+		{name: "b", stat: atime(1577934245 - 3600)},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, AccessTime: true, ATimeSort: true,
+		TimeFormat: "2006-01-02 15:04"}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	date := func(sec int64) string {
+		return time.Unix(sec, 0).Format(opts.TimeFormat)
+	}
+	expected := date(1577934245) + " root\n" +
+		date(1577934245-3600) + " ┣━ b\n" +
+		date(1577934245+3600) + " ┗━ a\n"
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !openbsd && !dragonfly && !android && !solaris && !darwin && !freebsd && !netbsd && !windows
+// +build !linux,!openbsd,!dragonfly,!android,!solaris,!darwin,!freebsd,!netbsd,!windows
+
+package tree
+
+import (
+	"os"
+	"time"
+)
+
+// getAccessTime returns the last access time of the file, it isn't known on
+// this OS.
+func getAccessTime(fi os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
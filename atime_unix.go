@@ -0,0 +1,19 @@
+//go:build linux || openbsd || dragonfly || android || solaris
+// +build linux openbsd dragonfly android solaris
+
+package tree
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// getAccessTime returns the last access time of the file.
+func getAccessTime(fi os.FileInfo) (time.Time, bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Atim.Unix()), true
+}
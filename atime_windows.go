@@ -0,0 +1,16 @@
+package tree
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// getAccessTime returns the last access time of the file.
+func getAccessTime(fi os.FileInfo) (time.Time, bool) {
+	attr, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, attr.LastAccessTime.Nanoseconds()), true
+}
@@ -0,0 +1,18 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package tree
+
+import (
+	"syscall"
+	"time"
+)
+
+// getBirthTime returns the creation time of the file, from the stat.
+func getBirthTime(node *Node) (time.Time, bool) {
+	stat, ok := node.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Birthtimespec.Unix()), true
+}
@@ -0,0 +1,23 @@
+package tree
+
+import (
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// getBirthTime returns the creation time of the file, from statx(2). It's
+// only known on the filesystems that store it (ext4, xfs, btrfs ...).
+func getBirthTime(node *Node) (time.Time, bool) {
+	if _, ok := node.Sys().(*syscall.Stat_t); !ok || node.virtual {
+		return time.Time{}, false
+	}
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, node.path, unix.AT_SYMLINK_NOFOLLOW,
+		unix.STATX_BTIME, &stx)
+	if err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !windows
+// +build !linux,!darwin,!freebsd,!netbsd,!windows
+
+package tree
+
+import "time"
+
+// getBirthTime returns the creation time of the file, it isn't known on this
+// OS.
+func getBirthTime(node *Node) (time.Time, bool) {
+	return time.Time{}, false
+}
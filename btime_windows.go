@@ -0,0 +1,15 @@
+package tree
+
+import (
+	"syscall"
+	"time"
+)
+
+// getBirthTime returns the creation time of the file, from the attributes.
+func getBirthTime(node *Node) (time.Time, bool) {
+	attr, ok := node.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, attr.CreationTime.Nanoseconds()), true
+}
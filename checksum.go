@@ -0,0 +1,76 @@
+package tree
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// checksums are the hashes for Options.Checksum
+var checksums = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"xxh64":  func() hash.Hash { return newXXH64() },
+}
+
+// ParseChecksum checks the hash name for Options.Checksum, and returns it.
+func ParseChecksum(s string) (string, error) {
+	if checksumHash(s) == nil {
+		return "", fmt.Errorf("invalid checksum %q, should be one of: sha256,md5,xxh64", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// checksumHash returns the hash for the name in any case, or nil if it isn't
+// one of the checksums.
+func checksumHash(name string) func() hash.Hash {
+	return checksums[strings.ToLower(name)]
+}
+
+// checksumLen returns the width of the hex digests of the hash, 0 if it isn't
+// one of the checksums.
+func checksumLen(name string) int {
+	newHash := checksumHash(name)
+	if newHash == nil {
+		return 0
+	}
+	return newHash().Size() * 2
+}
+
+// fileChecksum returns the hex digest of the file at path, or "" if it can't
+// be read.
+func fileChecksum(opts *Options, path string) string {
+	newHash := checksumHash(opts.Checksum)
+	ofs, ok := opts.Fs.(OpenFs)
+	if newHash == nil || !ok {
+		return ""
+	}
+	f, err := ofs.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkChecksum sets the checksum of the node, if it's needed. It's done
+// while visiting, so the files are read by the visiting goroutines. The
+// files that won't be shown aren't read.
+func (node *Node) checkChecksum(opts *Options) {
+	if opts.Checksum == "" || !node.Mode().IsRegular() {
+		return
+	}
+	if contentSkipped(opts, node) || skipFile(opts, node) != "" {
+		return
+	}
+	node.csum = fileChecksum(opts, node.path)
+}
@@ -0,0 +1,70 @@
+package tree
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// openCounter counts the files opened through the MapFs
+type openCounter struct {
+	*MapFs
+	opens int32
+}
+
+func (fs *openCounter) Open(p string) (io.ReadCloser, error) {
+	atomic.AddInt32(&fs.opens, 1)
+	return fs.MapFs.Open(p)
+}
+
+func TestChecksum(t *testing.T) {
+	if _, err := ParseChecksum("crc"); err == nil {
+		t.Error("expected an error for an unknown checksum")
+	}
+	mfs := NewMapFs().
+		AddFile("root/a", []byte("abc"), 0644, testTime).
+		AddFile("root/b/c", []byte("a"), 0644, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"xxh64", &Options{Checksum: "xxh64"}, `
+                 root
+44bc2cf5ad770999 ┣━ a
+                 ┗━ b
+d24ec4f1a98c6e5b   ┗━ c
+`, 0, 0},
+		{"upper-case", &Options{Checksum: "XXH64"}, `
+                 root
+44bc2cf5ad770999 ┣━ a
+                 ┗━ b
+d24ec4f1a98c6e5b   ┗━ c
+`, 0, 0},
+		{"unknown", &Options{Checksum: "blake2"}, `
+root
+┣━ a
+┗━ b
+  ┗━ c
+`, 0, 0},
+	})
+	opts := &Options{Fs: mfs, OutFile: out, Checksum: "blake2"}
+	conf := RunConfig{Options: opts, Paths: []string{"root"}}
+	if _, err := Run(context.Background(), conf); err == nil {
+		t.Error("run: expected an error for an unknown checksum")
+	}
+
+	// The files that aren't shown aren't read
+	ofs := &openCounter{MapFs: mfs}
+	for _, test := range []struct {
+		opts  *Options
+		opens int32
+	}{
+		{&Options{Checksum: "xxh64", DirsOnly: true}, 0},
+		{&Options{Checksum: "xxh64", Pattern: "c"}, 1},
+	} {
+		ofs.opens = 0
+		visitMapFs(ofs, test.opts)
+		if ofs.opens != test.opens {
+			t.Errorf("opened %d files, expected %d", ofs.opens, test.opens)
+		}
+	}
+}
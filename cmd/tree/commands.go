@@ -0,0 +1,49 @@
+package main
+
+import "flag"
+
+// command is a subcommand, it's a mode of the listing that sets the defaults
+// of some flags, the options after it can still change them.
+type command struct {
+	name  string
+	flags map[string]string // The flag defaults, by name
+}
+
+// commands are the subcommands, tree without one is list.
+var commands = []command{
+	{"list", nil},
+	{"du", map[string]string{
+		"dirs-only": "true",
+		"human":     "true",
+		"sort":      "size",
+		"r":         "true",
+	}},
+	{"stats", map[string]string{
+		"human":      "true",
+		"stats-only": "true",
+	}},
+	{"watch", map[string]string{
+		"watch": "true",
+	}},
+}
+
+// parseCommand sets the flag defaults for the command, if the first arg is
+// one, and returns the args after it. Only the first arg is looked at, so
+// ./du lists the du directory.
+func parseCommand(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		for name, val := range cmd.flags {
+			if err := flag.Set(name, val); err != nil {
+				panic(err)
+			}
+		}
+		return args[1:]
+	}
+	return args
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// outputHash is the SHA-256 of the output, for --output-hash
+type outputHash struct {
+	hash.Hash
+	dest string // append, sidecar or a file name
+}
+
+// newOutputHash returns the hash for the --output-hash destination, or an
+// error if it can't be used with the output file. The hash is of what this
+// run writes, so it can't be appended to a file or encoded after.
+func newOutputHash(dest, output string, appending bool,
+	encoding string) (*outputHash, error) {
+	if dest == "sidecar" && output == "" {
+		return nil, errors.New(msgs.Sprintf("--output-hash sidecar needs --output"))
+	}
+	if appending {
+		return nil, errors.New(msgs.Sprintf("--output-hash can't be used with --append"))
+	}
+	if encoding != "" {
+		return nil, errors.New(msgs.Sprintf("--output-hash can't be used with --output-encoding"))
+	}
+	return &outputHash{Hash: sha256.New(), dest: dest}, nil
+}
+
+// writer returns w with everything written to it hashed too.
+func (oh *outputHash) writer(w io.Writer) io.Writer {
+	return io.MultiWriter(w, oh)
+}
+
+// finish appends the hash to the output, before it's closed, for append.
+func (oh *outputHash) finish(w io.Writer) error {
+	if oh.dest != "append" {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "sha256 %x\n", oh.Sum(nil))
+	return err
+}
+
+// save writes the hash like sha256sum, so "sha256sum -c" can check it,
+// after the output is closed. The sidecar is the output name + ".sha256".
+func (oh *outputHash) save(output string) error {
+	name := "-"
+	if output != "" {
+		name = filepath.Base(output)
+	}
+	line := fmt.Sprintf("%x  %s\n", oh.Sum(nil), name)
+	switch oh.dest {
+	case "append":
+		return nil
+	case "sidecar":
+		return ioutil.WriteFile(output+".sha256", []byte(line), 0644)
+	}
+	return ioutil.WriteFile(oh.dest, []byte(line), 0644)
+}
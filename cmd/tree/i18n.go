@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// translations are the messages for the other languages, keyed by the
+// English format (or the id, like "usage"). See translations.go.
+var translations = newCatalog(map[language.Tag]map[string]string{
+	language.German: messagesDE,
+})
+
+// msgs prints the usage, warnings and errors in the user's language, the
+// numbers are formatted for it too.
+var msgs = message.NewPrinter(userLanguage(), message.Catalog(translations))
+
+// newCatalog returns the catalog for the translations, English is used for
+// the messages that aren't translated.
+func newCatalog(langs map[language.Tag]map[string]string) catalog.Catalog {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	for tag, messages := range langs {
+		for key, msg := range messages {
+			b.SetString(tag, key, msg)
+		}
+	}
+	return b
+}
+
+// userLanguage returns the best translation for the locale, from LC_ALL,
+// LC_MESSAGES or LANG (eg. de_DE.UTF-8).
+func userLanguage() language.Tag {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		lang := os.Getenv(env)
+		if lang == "" {
+			continue
+		}
+		if i := strings.IndexAny(lang, ".@"); i != -1 {
+			lang = lang[:i]
+		}
+		tag, _, conf := translations.Matcher().Match(language.Make(lang))
+		if conf == language.No {
+			break
+		}
+		return tag
+	}
+	return language.English
+}
+
+// usageText returns the usage for the user's language, the % in it aren't
+// format verbs.
+func usageText() string {
+	return msgs.Sprintf(message.Key("usage", strings.Replace(usage, "%", "%%", -1)))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pager is the running pager, the output is written to pagerIn
+var (
+	pager   *exec.Cmd
+	pagerIn *os.File
+)
+
+// checkPager returns an error if the --pager mode isn't valid.
+func checkPager(mode string) error {
+	switch mode {
+	case "auto", "always", "never":
+		return nil
+	}
+	return errors.New(msgs.Sprintf("pager '%s' not valid, should be one of: auto,always,never", mode))
+}
+
+// startPager runs $PAGER (def: less) with the output piped to it, and
+// returns the pipe. With auto, less quits if the output fits on one screen.
+// Like git, LESS is only set if it isn't already.
+func startPager(mode string) (*os.File, error) {
+	cmdline, ok := os.LookupEnv("PAGER")
+	if !ok {
+		cmdline = "less"
+	}
+	args := strings.Fields(cmdline)
+	if len(args) == 0 || args[0] == "cat" {
+		return nil, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		less := "FRX"
+		if mode == "always" {
+			less = "RX"
+		}
+		cmd.Env = append(cmd.Env, "LESS="+less)
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	pager, pagerIn = cmd, w
+	return w, nil
+}
+
+// stopPager ends the output to the pager, and waits for the user to quit it.
+func stopPager() {
+	if pager == nil {
+		return
+	}
+	pagerIn.Close()
+	pager.Wait()
+	pager = nil
+}
@@ -0,0 +1,77 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// The ioprio_set values, from linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// lowerPriority makes the process use the disks only when nothing else is,
+// and gives it the lowest CPU priority. On Linux both only change a thread,
+// so it's done for every thread of the runtime (the new threads inherit
+// it). The tasks are read again until there are no new ones, as a thread
+// could be started from one that wasn't done yet.
+func lowerPriority() error {
+	done := make(map[int]bool)
+	for {
+		tids, err := taskIDs()
+		if err != nil {
+			return err
+		}
+		todo := 0
+		for _, tid := range tids {
+			if done[tid] {
+				continue
+			}
+			done[tid] = true
+			todo++
+			if err := lowerThreadPriority(tid); err != nil && err != syscall.ESRCH {
+				return err
+			}
+		}
+		if todo == 0 {
+			return nil
+		}
+	}
+}
+
+// taskIDs returns the ids of the threads of the process.
+func taskIDs() ([]int, error) {
+	d, err := os.Open("/proc/self/task")
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	var tids []int
+	for _, name := range names {
+		if tid, err := strconv.Atoi(name); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// lowerThreadPriority sets the idle IO priority and the lowest CPU priority
+// of the thread.
+func lowerThreadPriority(tid int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess,
+		uintptr(tid), ioprioClassIdle<<ioprioClassShift)
+	if errno != 0 {
+		return errno
+	}
+	return syscall.Setpriority(syscall.PRIO_PROCESS, tid, 19)
+}
@@ -0,0 +1,11 @@
+//go:build plan9 || windows
+// +build plan9 windows
+
+package main
+
+import "errors"
+
+// lowerPriority isn't supported.
+func lowerPriority() error {
+	return errors.New(msgs.Sprintf("not supported on this OS"))
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !plan9 && !windows
+// +build !linux,!plan9,!windows
+
+package main
+
+import "syscall"
+
+// lowerPriority gives the process the lowest CPU priority, there's no IO
+// priority.
+func lowerPriority() error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, 20)
+}
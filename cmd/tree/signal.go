@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/james-antill/tree"
+)
+
+// visitStats are the counts from Options.Progress, for the progress signals
+type visitStats struct {
+	mu    sync.Mutex
+	start time.Time
+	dirs  int64
+	files int64
+	path  string
+}
+
+func (s *visitStats) progress(dirs, files int64, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirs, s.files, s.path = dirs, files, path
+}
+
+// print writes the progress to stderr, like dd does.
+func (s *visitStats) print() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secs := time.Since(s.start).Seconds()
+	msgs.Fprintf(os.Stderr, "tree: %d dirs, %d files visited in %.1fs (%.0f/s): %s\n",
+		s.dirs, s.files, secs, float64(s.dirs+s.files)/secs, s.path)
+}
+
+// handleSignals cancels the ctx on the first SIGINT, so the partial tree is
+// printed, and exits on the second. The progressSignals print the progress.
+func handleSignals(opts *tree.Options) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	stats := &visitStats{start: time.Now()}
+	if prev := opts.Progress; prev != nil {
+		opts.Progress = func(dirs, files int64, path string) {
+			stats.progress(dirs, files, path)
+			prev(dirs, files, path)
+		}
+	} else {
+		opts.Progress = stats.progress
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, append(progressSignals, os.Interrupt)...)
+	go func() {
+		for sig := range sigs {
+			if sig != os.Interrupt {
+				stats.print()
+				continue
+			}
+			if ctx.Err() != nil {
+				if tmpOutput != "" {
+					os.Remove(tmpOutput)
+				}
+				os.Exit(130)
+			}
+			msgs.Fprintf(os.Stderr, "tree: interrupted, printing the partial tree (^C again to quit)\n")
+			cancel()
+		}
+	}()
+	return ctx
+}
@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// progressSignals print the progress of the visit, SIGINFO is ^T
+var progressSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGINFO}
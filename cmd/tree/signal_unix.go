@@ -0,0 +1,12 @@
+//go:build aix || linux || solaris
+// +build aix linux solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// progressSignals print the progress of the visit
+var progressSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,32 @@
+package main
+
+// The translations of the messages, keyed by the English format. The
+// messages not in a language (like the "usage") are printed in English.
+// To add a language, add a map here and to the translations in i18n.go.
+
+// messagesDE are the German messages
+var messagesDE = map[string]string{
+	"sort type '%s' not valid, should be one of: name,version,size,mtime,ctime,btime,atime,entries": "Sortierung '%s' ist ungültig, erlaubt sind: name,version,size,mtime,ctime,btime,atime,entries",
+	"type '%s' not valid, should be one of: text,binary,f,d,l,x":                                    "Typ '%s' ist ungültig, erlaubt sind: text,binary,f,d,l,x",
+	"mode '%s' not valid, should be octal":                                                          "Modus '%s' ist ungültig, er muss oktal sein",
+	"pager '%s' not valid, should be one of: auto,always,never":                                     "Pager '%s' ist ungültig, erlaubt sind: auto,always,never",
+	"not supported on this OS":                                                                      "auf diesem Betriebssystem nicht unterstützt",
+	"%s is not under any of: %s":                                                                    "%s liegt unter keinem von: %s",
+	"%s: shown\n":                                                                                   "%s: angezeigt\n",
+	"%s: hidden by %s\n":                                                                            "%s: ausgeblendet durch %s\n",
+	"%c %d dirs, %d files: %s":                                                                      "%c %d Verzeichnisse, %d Dateien: %s",
+	"--watch needs the output on stdout":                                                            "--watch braucht die Ausgabe auf stdout",
+	"--dirsfirst can't be used with --dirslast":                                                     "--dirsfirst kann nicht mit --dirslast verwendet werden",
+	"--stats can't be used with --stream":                                                           "--stats kann nicht mit --stream verwendet werden",
+	"--watch can't be used with --output-hash":                                                      "--watch kann nicht mit --output-hash verwendet werden",
+	"--output-hash can't be used with --append":                                                     "--output-hash kann nicht mit --append verwendet werden",
+	"--output-hash can't be used with --output-encoding":                                            "--output-hash kann nicht mit --output-encoding verwendet werden",
+	"--ignore-file can't be used with --no-treeignore":                                              "--ignore-file kann nicht mit --no-treeignore verwendet werden",
+	"--output-hash sidecar needs --output":                                                          "--output-hash sidecar braucht --output",
+
+	"tree: can't lower the priority: %s\n": "tree: Priorität kann nicht gesenkt werden: %s\n",
+	"tree: can't run the pager: %s\n":      "tree: Pager kann nicht gestartet werden: %s\n",
+	"tree: %d entries unreadable (permission denied), run with elevated privileges for complete results\n": "tree: %d Einträge nicht lesbar (Zugriff verweigert), für vollständige Ergebnisse mit erhöhten Rechten ausführen\n",
+	"tree: %d dirs, %d files visited in %.1fs (%.0f/s): %s\n":                                              "tree: %d Verzeichnisse, %d Dateien in %.1fs besucht (%.0f/s): %s\n",
+	"tree: interrupted, printing the partial tree (^C again to quit)\n":                                    "tree: unterbrochen, der bisherige Baum wird ausgegeben (^C erneut zum Beenden)\n",
+}
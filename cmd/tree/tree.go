@@ -4,13 +4,20 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/james-antill/tree"
+	"github.com/james-antill/tree/tarfs"
+	"github.com/james-antill/tree/zipfs"
 	"golang.org/x/crypto/ssh/terminal"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 var (
@@ -25,8 +32,42 @@ var (
 	l = flag.Bool("follow", false, "")
 	o = flag.String("output", "", "")
 
+	appendOut = flag.Bool("append", false, "")
+	outHash   = flag.String("output-hash", "", "")
+
 	ignorecase = flag.Bool("ignore-case", false, "")
 	noreport   = flag.Bool("noreport", false, "")
+	linksOnly  = flag.Bool("links-only", false, "")
+	ftype      = flag.String("type", "", "")
+	explain    = flag.String("explain", "", "")
+	where      = flag.String("where", "", "")
+	glob       = flag.Bool("glob", false, "")
+	noBackups  = flag.Bool("no-backup-filter", false, "")
+	newer      = flag.String("newer", "", "")
+	older      = flag.String("older", "", "")
+	owner      = flag.String("user", "", "")
+	group      = flag.String("group", "", "")
+	ignoreErrs stringList
+	progress   = flag.Bool("progress", false, "")
+	pagerMode  = flag.String("pager", "never", "")
+	exclPseudo = flag.Bool("exclude-pseudo", false, "")
+	noTreeIgn  = flag.Bool("no-treeignore", false, "")
+	gitIgnore  = flag.Bool("gitignore", false, "")
+	ignoreFile = flag.String("ignore-file", "", "")
+	threads    = flag.Int("threads", 0, "")
+	lowPrio    = flag.Bool("low-priority", false, "")
+	sample     = flag.String("sample", "", "")
+	throttle   = flag.Int("throttle", 0, "")
+	usageRep   = flag.String("usage-report", "", "")
+	depthRep   = flag.Bool("depth-report", false, "")
+	stats      = flag.Bool("stats", false, "")
+	statsOnly  = flag.Bool("stats-only", false, "")
+	statsTop   = flag.Int("stats-top", 10, "")
+	stream     = flag.Bool("stream", false, "")
+	watchMode  = flag.Bool("watch", false, "")
+	ndjson     = flag.Bool("ndjson", false, "")
+	csvOut     = flag.Bool("csv", false, "")
+	tsvOut     = flag.Bool("tsv", false, "")
 
 	// Files
 	D = flag.Bool("mtime", false, "")
@@ -37,8 +78,34 @@ var (
 	s = flag.Bool("bytes", false, "")
 	u = flag.Bool("uid", false, "")
 
-	device = flag.Bool("device", false, "")
-	inodes = flag.Bool("inodes", false, "")
+	content     = flag.Bool("content", false, "")
+	device      = flag.Bool("device", false, "")
+	inodes      = flag.Bool("inodes", false, "")
+	nlinks      = flag.Bool("nlink", false, "")
+	hashMaxSize = flag.String("hash-max-size", "", "")
+	checksum    = flag.String("checksum", "", "")
+	hideEmpty   = flag.Bool("hide-empty-size", false, "")
+	allocated   = flag.Bool("allocated", false, "")
+	countLinks  = flag.Bool("count-links", false, "")
+	savings     = flag.Bool("savings", false, "")
+	sparse      = flag.Bool("sparse", false, "")
+	mtimeRollup = flag.Bool("mtime-rollup", false, "")
+	octalPerms  = flag.Bool("octal-permissions", false, "")
+	xattrMarks  = flag.Bool("xattr", false, "")
+	xattrNames  = flag.Bool("xattr-names", false, "")
+	selinuxCtx  = flag.Bool("context", false, "")
+	linkTargets = flag.Bool("link-targets", false, "")
+	isoTime     = flag.Bool("iso-time", false, "")
+	timeFmt     = flag.String("timefmt", "", "")
+	relTime     = flag.Bool("relative-time", false, "")
+	btime       = flag.Bool("btime", false, "")
+	atime       = flag.Bool("atime", false, "")
+	expectFile  = flag.String("expect-file-mode", "", "")
+	expectDir   = flag.String("expect-dir-mode", "", "")
+	expectOwner = flag.String("expect-owner", "", "")
+	expectGroup = flag.String("expect-group", "", "")
+	emptyText   = flag.String("empty-size-text", "", "")
+	noHash      stringList
 
 	// Sort
 	U         = flag.Bool("U", false, "")
@@ -47,20 +114,57 @@ var (
 	c         = flag.Bool("c", false, "")
 	r         = flag.Bool("r", false, "")
 	dirsfirst = flag.Bool("dirsfirst", false, "")
+	dirslast  = flag.Bool("dirslast", false, "")
 	sort      = flag.String("sort", "", "")
 
 	// Graphics
 	C = flag.Bool("C", false, "")
 	F = flag.Bool("classify", false, "")
+	H = flag.Bool("html", false, "")
 	J = flag.Bool("nojoin", false, "")
 	Q = flag.Bool("quote", false, "")
 
 	i = flag.Bool("noindent", false, "")
 
 	numericIDs = flag.Bool("numeric-uid-gid", false, "")
+	quoteRoot  = flag.Bool("quote-root", false, "")
+	joinCounts = flag.Bool("join-counts", false, "")
+	pathSep    = flag.String("path-sep", "", "")
+	dotRoot    = flag.Bool("dot-root", false, "")
+	charset    = flag.String("charset", "", "")
+	compat     = flag.String("compat", "", "")
+	dircolors  = flag.String("dircolors", "", "")
+	ageBuckets = flag.String("age-buckets", "", "")
+	hyperlinks = flag.Bool("hyperlink", false, "")
+	asciiNames = flag.Bool("ascii-names", false, "")
+	termWidth  = flag.Int("width", -1, "")
+	wrapNames  = flag.Bool("wrap", false, "")
+	a11y       = flag.Bool("a11y", false, "")
+	baseHREF   = flag.String("base-href", "", "")
+	format     = flag.String("format", "", "")
+
+	outputFormat = flag.String("output-format", "", "")
+	outputEnc    = flag.String("output-encoding", "", "")
 )
 
-var usage = `Usage: tree [options...] [paths...]
+// tmpOutput is the temp. file written to for --output, until it's complete
+var tmpOutput string
+
+var usage = `Usage: tree [command] [options...] [paths...]
+
+Paths can also be tar archives (.tar, .tar.gz, .tgz, .tar.bz2, .tar.xz ...)
+or zip archives (.zip, .jar, .whl).
+
+Commands:
+    list                 List the tree (def).
+    du                   List the directories by size, the biggest first
+                         (like -d -h --sort size -r).
+    stats                Print the statistics of the tree, instead of the
+                         tree (like -h --stats-only).
+    watch                List the tree again when anything in it changes
+                         (like --watch).
+    The options after the command can change its defaults, and ./NAME lists
+    a directory named like a command.
 
 Options:
     ----------------------- Listing options ----------------------
@@ -72,18 +176,122 @@ Options:
     -f --full-path       Print the full path prefix for each file.
     -l --follow          Follow symbolic links like directories.
     -o --output filename Output to file instead of stdout.
+    --output-format X    Select output: text,json,ndjson,xml,html,csv,tsv,md
+                         (def: from the --output extension, or text).
+    --output-encoding X  Write the output as: utf-8,utf-8-bom,utf-16le
+                         (def: utf-8, utf-16le has a BOM for Windows tools).
+    --append             Append to the output file, instead of replacing it.
+    --output-hash X      Write the SHA-256 of the output to the file X, like
+                         sha256sum. X can be sidecar for the --output file
+                         name + .sha256, or append for a "sha256 <hex>" line
+                         after the output (of the output before it). Not
+                         with --append or --output-encoding.
     --ignore-case        Ignore case when pattern matching.
+    --glob               The -P and -I patterns are globs, like '*.go|*.c' or
+                         'src/**/*_test.go', instead of regexps.
+    --no-backup-filter   List backup files (*~, *.bak) too.
+    --links-only         List symbolic links only (and their dirs.).
+    --type X             List only files of type: text,binary, or f (file),
+                         d (directory), l (symlink), x (executable), eg. f,l.
+    --explain PATH       Show which option hides PATH, instead of the tree.
+    --ignore-errors X    Don't show errors for paths under X (/proc,/sys).
+    --exclude-pseudo     Skip pseudo filesystems, like proc, sysfs and cgroup.
+    --no-treeignore      Don't skip entries matching the gitignore style
+                         patterns in ~/.treeignore and each directory's
+                         .treeignore file.
+    --ignore-file FILE   Use the patterns in FILE, instead of ~/.treeignore.
+    --gitignore          Skip entries matching the .gitignore files, and the
+                         global excludes file (core.excludesFile).
+    --progress           Show the progress of the listing on stderr.
+                         SIGUSR1 (or ^T on BSD/macOS) prints it once, and
+                         ^C prints the tree listed so far.
+    --pager X            Show the tree in $PAGER (def: less) for terminals:
+                         auto (if it's longer than the screen), always, or
+                         never (def).
+    --stream             Print each directory as soon as it's read (no
+                         directory sizes, -L -1 shows everything, no joins).
+    --watch              Print the tree again when anything in it changes,
+                         or print the changes (+ created, - removed,
+                         ~ modified) when the output isn't a terminal.
+    --threads N          Visit N directories at once (def: 32, 1=serial).
+    --low-priority       Use idle IO priority and the lowest CPU priority,
+                         for background scans.
+    --throttle N         Make at most N stat/readdir calls per second.
+    --sample N%          Read only N% of the subdirectories of each directory,
+                         and report the estimated totals.
+    --newer X            List only files modified after X, a date (2023-01-01)
+                         or a time before now (7d, 12h).
+    --older X            List only files modified before X, like --newer.
+    --user NAME          List only files owned by the user (name or uid).
+    --group NAME         List only files owned by the group (name or gid).
+    --where EXPR         List only files matching the expression.
+                         Eg. 'size > 10MB && ext == ".log" && mtime < now-30d'
     --noreport	         Turn off file/directory count at end of tree listing.
+    --usage-report X     Print the entries (inodes) and size of each top
+                         level directory, sorted by: entries,size,name.
+    --depth-report       Add the max depth and the longest path to the
+                         report, for tools with path length limits.
+    --stats              Print statistics after the report: the counts, a
+                         histogram of the file sizes, and the largest files,
+                         directories and extensions. Only for the text
+                         output, and not with --stream.
+    --stats-only         Print the statistics instead of the tree.
+    --stats-top N        The number of the largest entries in the stats
+                         (default 10).
+    --ndjson             Stream each entry as a line of JSON, while visiting.
+    --csv                Output a CSV row for each entry, instead of a tree.
+    --tsv                Output a TSV row for each entry, instead of a tree.
 
     ----------------------- File options -------------------------
     -D --mtime           Print the date of last modification change.
+    --mtime-rollup       Use the newest date under directories for -D and -t.
+    --iso-time           Print -D dates as ISO, not for the locale (LANG).
+    --timefmt X          Print -D dates with the Go layout or strftime format
+                         X, or iso for ISO-8601. Eg. '%b %e %H:%M'.
+    --relative-time      Print -D dates as ages, like "3h ago" or "2 years ago".
+    --btime              Print the creation (birth) date of each file, in the
+                         -D format. It's blank if the filesystem doesn't
+                         store it.
+    --atime              Print the last access date of each file, in the -D
+                         format.
     -g --gid             Displays file group owner or GID number.
     -h --human           Print the size in a more human readable way.
-    -p --protections     Print the protections for each file.
+    -p --protections     Print the protections for each file, with a + for
+                         POSIX ACLs like ls.
+    --octal-permissions  Print the protections in octal (eg. 0644).
+    --xattr              Print @ after the protections for files with extended
+                         attributes, like BSD ls.
+    --xattr-names        Print the names of the extended attributes.
+    --context            Print the SELinux security context of each file
+                         (Linux only).
+    --link-targets       Print the protections, size and date of the symlink
+                         targets. Broken symlinks are always marked.
+    --expect-file-mode X Flag files without the mode X (eg. 0644).
+    --expect-dir-mode X  Flag directories without the mode X (eg. 0755).
+    --expect-owner X     Flag entries not owned by the user X.
+    --expect-group X     Flag entries not owned by the group X.
+                         Any flagged entries make the exit status 1, like
+                         any errors.
     -u --uid             Displays file owner or UID number.
     -s --bytes           Print the size in bytes of each file.
+    --content            Print if each file is text or binary.
+    --checksum X         Print the digest of each file, with the hash:
+                         sha256, md5 or xxh64 (fast, not for security).
+    --hash-max-size X    Don't read the content of files bigger than X (100M).
+    --no-hash X          Don't read the content of files matching X (*.iso).
+    --allocated          Print the size allocated on disk after the size
+                         (-s or -h), like 1.2G/1.4G.
+    --count-links        Count hard linked files in the directory sizes for
+                         each link, like du -l (def: only the first).
+    --savings            Flag files using less than half their size on disk
+                         (sparse or compressed), and print the total saved.
+    --sparse             Flag the sparse files (with holes), which use much
+                         less disk than their size.
+    --hide-empty-size    Don't print the size of empty directories.
+    --empty-size-text X  Print X as the size of empty directories (empty).
     --device             Print device ID number to which each file belongs.
     --inodes             Print inode number of each file.
+    --nlink              Print the number of hard links to each file.
 
     ---------------------- Sorting options -----------------------
     -U                   Leave files unsorted.
@@ -92,23 +300,134 @@ Options:
     -t                   Sort files by last modification time.
     -v                   Sort files alphanumerically by version.
     --dirsfirst          List directories before files (-U disables).
-    --sort X             Select sort: name,version,size,mtime,ctime.
+    --dirslast           List files before directories (-U disables).
+    --sort X             Select sort: name,version,size,mtime,ctime,btime,
+                         atime, or entries (dirs. by the number of entries
+                         under them, after the files by size).
 
     ---------------------- Graphics options ----------------------
     -C --color           Turn colorization on always. (def: on for terminals)
     -F --classify        Append indicator (one of */=>@|) to entries.
+    -H --html            Print a HTML page, with collapsible directories.
     -J --nojoin          Turn joining of single directories off.
+    --join-counts        Print the files and size of joined directories.
+    --path-sep X         Separator for joined and full paths (def: native).
     -Q --quote           Quote filenames with double quotes.
     -i --noindent        Don't print indentation lines.
     --numeric-uid-gid    Print the user and group IDs as numbers.
+    --quote-root         Quote the root paths with double quotes.
+    --dot-root           Print the root as "." when it's the current dir.
+    --charset X          Draw the tree with: utf-8 (def), ascii, or custom
+                         strings 'branch,last,vertical,space[,cutoff]'.
+    --dircolors FILE     Color with the dircolors(1) database in FILE, like
+                         ~/.dir_colors.
+    --age-buckets X      Color the entries by the age of their date, in the
+                         buckets X (eg. 1d,1w,1m,1y, m is months), and print
+                         the files in each bucket after the report.
+    --width N            Truncate lines longer than N columns with an ellipsis
+                         (def: the terminal width, 0=no limit).
+    --wrap               Wrap long lines under the name, instead of truncating.
+    --ascii-names        Print the names with only ASCII, for consoles that
+                         can't show them: accents are removed and the other
+                         characters escaped (\u4e16). JSON, XML and CSV
+                         outputs keep the names.
+    --hyperlink          Make the names clickable file:// links, in terminals
+                         that support them (OSC 8).
+    --compat gnu         Print the text output like GNU tree (ascii lines,
+                         no joins or dynamic levels, the GNU report).
+    --a11y               Print each entry as "level N: path", for screen
+                         readers (no indentation, joins or colors).
+    --base-href X        Prefix for the links in the HTML output.
+    --format X           Print each entry with the Go text/template X,
+                         or an --output-format name.
+                         Eg. '{{.Indent}}{{.Name}} {{.Size}} {{.ModTime}}'
 `
 
-type fs struct{}
+// stringList is a flag that can be given multiple times, or comma separated
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+func (l *stringList) Set(val string) error {
+	*l = append(*l, strings.Split(val, ",")...)
+	return nil
+}
+
+// fs is the OS filesystem, with the contents of archives given as roots
+// mounted over the archive files.
+type fs struct {
+	mu       sync.RWMutex
+	archives map[string]tree.Fs
+}
+
+// archiveExts are the file extensions that are shown as dirs.
+var archiveExts = map[string]func(string) (tree.Fs, error){
+	".tar":     openTar,
+	".tar.gz":  openTar,
+	".tgz":     openTar,
+	".tar.bz2": openTar,
+	".tbz2":    openTar,
+	".tbz":     openTar,
+	".tar.xz":  openTar,
+	".txz":     openTar,
+	".zip":     openZip,
+	".jar":     openZip,
+	".whl":     openZip,
+}
+
+func openTar(root string) (tree.Fs, error) { return tarfs.Open(root) }
+func openZip(root string) (tree.Fs, error) { return zipfs.Open(root) }
+
+// mount opens the root as an archive, if it looks like one.
+func (f *fs) mount(root string) error {
+	var open func(string) (tree.Fs, error)
+	for ext, fn := range archiveExts {
+		if strings.HasSuffix(strings.ToLower(root), ext) {
+			open = fn
+		}
+	}
+	if open == nil {
+		return nil
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	afs, err := open(root)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.archives == nil {
+		f.archives = make(map[string]tree.Fs)
+	}
+	f.archives[root] = afs
+	return nil
+}
+
+// archive returns the Fs for the archive the path is in, or nil.
+func (f *fs) archive(path string) tree.Fs {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for root, afs := range f.archives {
+		if path == root ||
+			strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return afs
+		}
+	}
+	return nil
+}
 
 func (f *fs) Stat(path string) (os.FileInfo, error) {
+	if afs := f.archive(path); afs != nil {
+		return afs.Stat(path)
+	}
 	return os.Lstat(path)
 }
 func (f *fs) ReadDir(path string) ([]string, error) {
+	if afs := f.archive(path); afs != nil {
+		return afs.ReadDir(path)
+	}
 	dir, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -121,27 +440,51 @@ func (f *fs) ReadDir(path string) ([]string, error) {
 	return names, nil
 }
 
-func normPath(root string) (string, error) {
-	ret, err := filepath.Abs(root)
-	if err != nil {
-		return "", err
+func (f *fs) OpenDir(path string) (tree.DirReader, error) {
+	if afs := f.archive(path); afs != nil {
+		names, err := afs.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		return &nameList{names: names}, nil
 	}
-	root = ret
+	return os.Open(path)
+}
 
-	fi, err := os.Lstat(root)
-	if err != nil {
-		return "", err
+// nameList is a DirReader for the names read by an archive Fs
+type nameList struct {
+	names []string
+}
+
+func (l *nameList) Readdirnames(n int) ([]string, error) {
+	if len(l.names) == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(l.names) {
+		n = len(l.names)
 	}
+	names := l.names[:n]
+	l.names = l.names[n:]
+	return names, nil
+}
+func (l *nameList) Close() error { return nil }
 
-	if (fi.Mode() & os.ModeSymlink) != 0 {
-		nr, err := filepath.EvalSymlinks(root)
-		if err != nil {
-			return "", err
+func (f *fs) Open(path string) (io.ReadCloser, error) {
+	if afs := f.archive(path); afs != nil {
+		if ofs, ok := afs.(tree.OpenFs); ok {
+			return ofs.Open(path)
 		}
-		return nr, nil
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
 	}
-
-	return root, nil
+	return os.Open(path)
+}
+func (f *fs) Readlink(path string) (string, error) {
+	if afs := f.archive(path); afs != nil {
+		if rfs, ok := afs.(tree.ReadlinkFs); ok {
+			return rfs.Readlink(path)
+		}
+	}
+	return os.Readlink(path)
 }
 
 func main() {
@@ -166,16 +509,18 @@ func main() {
 
 	// Graphics
 	flag.BoolVar(F, "F", *F, "alias for classify")
+	flag.BoolVar(H, "H", *H, "alias for --html")
 	flag.BoolVar(J, "J", *J, "alias for --nojoin")
 	flag.BoolVar(Q, "Q", *Q, "alias for --quote")
 	flag.BoolVar(i, "i", *i, "alias for --noindent")
 
-	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Var(&noHash, "no-hash", "")
+	flag.Var(&ignoreErrs, "ignore-errors", "")
+
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usageText()) }
 
-	var nd, nf int
-	var ns int64
 	var dirs = []string{"."}
-	flag.Parse()
+	flag.CommandLine.Parse(parseCommand(os.Args[1:]))
 	// Make it work with leading dirs
 	if args := flag.Args(); len(args) > 0 {
 		dirs = args
@@ -183,94 +528,579 @@ func main() {
 	// Output file
 	var outFile = os.Stdout
 	var err error
-	if *o != "" {
-		outFile, err = os.Create(*o)
+	if *o != "" && *appendOut {
+		outFile, err = os.OpenFile(*o, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			errAndExit(err)
+		}
+	} else if *o != "" {
+		// Write to a temp. file and rename it when done, so anything reading
+		// the output never sees a partial tree.
+		outFile, err = ioutil.TempFile(filepath.Dir(*o), "."+filepath.Base(*o)+".")
 		if err != nil {
 			errAndExit(err)
 		}
+		tmpOutput = outFile.Name()
 	} else if terminal.IsTerminal(int(os.Stdout.Fd())) {
 		*C = true
+		if *termWidth < 0 {
+			*termWidth, _, _ = terminal.GetSize(int(os.Stdout.Fd()))
+		}
 	}
-	defer outFile.Close()
 	// Check sort-type
 	if *sort != "" {
 		switch *sort {
-		case "version", "mtime", "ctime", "name", "size":
+		case "version", "mtime", "ctime", "btime", "atime", "entries", "name",
+			"size":
 		default:
-			msg := fmt.Sprintf("sort type '%s' not valid, should be one of: "+
-				"name,version,size,mtime,ctime", *sort)
+			msg := msgs.Sprintf("sort type '%s' not valid, should be one of: "+
+				"name,version,size,mtime,ctime,btime,atime,entries", *sort)
 			errAndExit(errors.New(msg))
 		}
 	}
+	// Check type, the content types and the entry types can be combined
+	var ctype string
+	var types tree.EntryType
+	for _, typ := range strings.Split(*ftype, ",") {
+		switch typ {
+		case "":
+		case "text", "binary":
+			ctype = typ
+		default:
+			etype, err := tree.ParseTypes(typ)
+			if err != nil {
+				msg := msgs.Sprintf("type '%s' not valid, should be one of: "+
+					"text,binary,f,d,l,x", typ)
+				errAndExit(errors.New(msg))
+			}
+			types |= etype
+		}
+	}
+	// Check content limits
+	var contentMaxSize int64
+	if *hashMaxSize != "" {
+		contentMaxSize, err = tree.ParseSize(*hashMaxSize)
+		if err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check checksum
+	var csum string
+	if *checksum != "" {
+		if csum, err = tree.ParseChecksum(*checksum); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Ignored errors are compared with the normalised roots
+	for i := range ignoreErrs {
+		if path, err := filepath.Abs(ignoreErrs[i]); err == nil {
+			ignoreErrs[i] = path
+		}
+	}
+	// Check permission policy
+	var policy *tree.PermPolicy
+	for _, mode := range []string{*expectFile, *expectDir} {
+		if _, err := strconv.ParseUint(mode, 8, 32); mode != "" && err != nil {
+			errAndExit(errors.New(msgs.Sprintf("mode '%s' not valid, should be octal", mode)))
+		}
+	}
+	if *expectFile != "" || *expectDir != "" || *expectOwner != "" ||
+		*expectGroup != "" {
+		policy = &tree.PermPolicy{
+			FileMode: *expectFile,
+			DirMode:  *expectDir,
+			Owner:    *expectOwner,
+			Group:    *expectGroup,
+		}
+	}
+	// Check times
+	var newerTime, olderTime time.Time
+	now := time.Now()
+	if *newer != "" {
+		if newerTime, err = tree.ParseTime(*newer, now); err != nil {
+			errAndExit(err)
+		}
+	}
+	if *older != "" {
+		if olderTime, err = tree.ParseTime(*older, now); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check time format
+	var timeFormat string
+	if *timeFmt != "" {
+		if timeFormat, err = tree.ParseTimeFormat(*timeFmt); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check sample
+	var sampleFrac float64
+	if *sample != "" {
+		if sampleFrac, err = tree.ParseSample(*sample); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check charset
+	var graphics *tree.Graphics
+	if *charset != "" {
+		if graphics, err = tree.ParseGraphics(*charset); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check dircolors
+	var dirColors *tree.DirColors
+	if *dircolors != "" {
+		f, err := os.Open(*dircolors)
+		if err != nil {
+			errAndExit(err)
+		}
+		dirColors, err = tree.ParseDirColors(f)
+		f.Close()
+		if err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check age buckets
+	var ages *tree.AgeBuckets
+	if *ageBuckets != "" {
+		if ages, err = tree.ParseAgeBuckets(*ageBuckets, now); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check where expression
+	var whereExpr *tree.Where
+	if *where != "" {
+		whereExpr, err = tree.CompileWhere(*where)
+		if err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check output format, the old flags and the output extension are used if
+	// it's not given. --format can also be used for the format names.
+	oformat := *outputFormat
+	if oformat == "" && (&tree.Options{}).SetOutputFormat(*format) == nil {
+		oformat, *format = *format, ""
+	}
+	switch {
+	case oformat != "":
+	case *ndjson:
+		oformat = "ndjson"
+	case *csvOut:
+		oformat = "csv"
+	case *tsvOut:
+		oformat = "tsv"
+	case *H:
+		oformat = "html"
+	}
+	if oformat != "" {
+		if err := (&tree.Options{}).SetOutputFormat(oformat); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check output encoding
+	if _, err := tree.EncodeOutput(nil, *outputEnc); err != nil {
+		errAndExit(err)
+	}
+	// Check output hash
+	var ohash *outputHash
+	if *outHash != "" {
+		ohash, err = newOutputHash(*outHash, *o, *appendOut, *outputEnc)
+		if err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check pager
+	if err := checkPager(*pagerMode); err != nil {
+		errAndExit(err)
+	}
+	// Check format template
+	var tmpl *template.Template
+	if *format != "" {
+		tmpl, err = template.New("format").Parse(*format)
+		if err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check ignore file, the default ~/.treeignore can be missing
+	if *ignoreFile != "" {
+		if *noTreeIgn {
+			errAndExit(errors.New(msgs.Sprintf("--ignore-file can't be used with --no-treeignore")))
+		}
+		if _, err := os.Stat(*ignoreFile); err != nil {
+			errAndExit(err)
+		}
+	}
+	// Check sort
+	if *dirsfirst && *dirslast {
+		errAndExit(errors.New(msgs.Sprintf("--dirsfirst can't be used with --dirslast")))
+	}
+	// Check stats, the output format is checked by Run
+	if (*stats || *statsOnly) && *stream {
+		errAndExit(errors.New(msgs.Sprintf("--stats can't be used with --stream")))
+	}
 	// Set options
+	tfs := new(fs)
 	opts := &tree.Options{
 		// Required
-		Fs:      new(fs),
+		Fs:      tfs,
 		OutFile: outFile,
 		// List
 		All:        *a,
 		DirsOnly:   *d,
+		LinksOnly:  *linksOnly,
+		Content:    ctype,
+		Types:      types,
 		FullPath:   *f,
 		DeepLevel:  *L,
 		FollowLink: *l,
 		Pattern:    *P,
 		IPattern:   *I,
-		IgnoreCase: *ignorecase,
+		Glob:       *glob,
+		Where:      whereExpr,
+		Newer:      newerTime,
+		Older:      olderTime,
+		Sample:     sampleFrac,
+		User:       *owner,
+		Group:      *group,
+		// Errors
+		IgnoreErrors:   ignoreErrs,
+		ExcludePseudo:  *exclPseudo,
+		TreeIgnore:     !*noTreeIgn,
+		TreeIgnoreFile: treeIgnoreFile(*ignoreFile),
+		GitIgnore:      *gitIgnore,
+		GitExcludes:    gitExcludesFile(),
+		IgnoreCase:     *ignorecase,
 		// Files
-		ByteSize: *s,
-		UnitSize: *h,
-		FileMode: *p,
-		ShowUid:  *u,
-		ShowGid:  *g,
-		LastMod:  *D,
-		Inodes:   *inodes,
-		Device:   *device,
+		ByteSize:   *s,
+		UnitSize:   *h,
+		FileMode:   *p,
+		OctalMode:  *octalPerms,
+		Xattrs:     *xattrMarks,
+		XattrNames: *xattrNames,
+		Context:    *selinuxCtx,
+		Policy:     policy,
+		ShowUid:    *u,
+		ShowGid:    *g,
+		LastMod:    *D,
+		// Dates
+		LocaleTime:   !*isoTime,
+		TimeFormat:   timeFormat,
+		RelativeTime: *relTime,
+		BirthTime:    *btime,
+		AccessTime:   *atime,
+		Inodes:       *inodes,
+		Device:       *device,
+		LinkCount:    *nlinks,
+		LinkTargets:  *linkTargets,
+		CountLinks:   *countLinks,
+		// Mtime
+		MtimeRollup: *mtimeRollup,
+		Allocated:   *allocated,
+		Savings:     *savings,
+		Sparse:      *sparse,
+		// Empty dirs.
+		HideEmptySize: *hideEmpty || *emptyText != "",
+		EmptySizeText: *emptyText,
+		// Content
+		ShowContent:    *content,
+		ContentMaxSize: contentMaxSize,
+		NoContent:      noHash,
+		Checksum:       csum,
 		// Sort
 		NoSort:    *U,
 		ReverSort: *r,
 		DirSort:   *dirsfirst,
+		DirLast:   *dirslast,
 		VerSort:   *v || *sort == "version",
 		ModSort:   *t || *sort == "mtime",
 		CTimeSort: *c || *sort == "ctime",
+		BTimeSort: *sort == "btime",
+		ATimeSort: *sort == "atime",
+		CountSort: *sort == "entries",
 		NameSort:  *sort == "name",
 		SizeSort:  *sort == "size",
 		// Graphics
-		NoIndent:   *i,
-		Colorize:   *C,
-		JoinSingle: !*J,
-		Classify:   *F,
-		Quotes:     *Q,
-		NumericIDs: *numericIDs,
+		NoIndent:    *i,
+		Colorize:    *C,
+		JoinSingle:  !*J,
+		Classify:    *F,
+		Quotes:      *Q,
+		NumericIDs:  *numericIDs,
+		QuoteRoot:   *quoteRoot,
+		DotRoot:     *dotRoot,
+		Graphics:    graphics,
+		DirColors:   dirColors,
+		AgeBuckets:  ages,
+		Hyperlink:   *hyperlinks,
+		ASCIINames:  *asciiNames,
+		Width:       *termWidth,
+		WrapNames:   *wrapNames,
+		JoinCounts:  *joinCounts,
+		PathSep:     *pathSep,
+		BaseHREF:    *baseHREF,
+		Template:    tmpl,
+		NoReport:    *noreport,
+		UsageReport: *usageRep,
+		DepthReport: *depthRep,
+		Stats:       *stats,
+		StatsOnly:   *statsOnly,
+		StatsTop:    *statsTop,
+		Stream:      *stream,
+		Encoding:    *outputEnc,
+		// Visit
+		Concurrency: *threads,
+		Throttle:    *throttle,
+	}
+	if opts.PathSep == "native" {
+		opts.PathSep = ""
+	}
+	if *a11y {
+		opts.Formatter = tree.A11yFormatter{}
+		opts.Colorize = false
+		opts.JoinSingle = false
+		opts.FullPath = false
+	}
+	if *noBackups {
+		opts.SkipSuffixes = []string{}
+	}
+	if *compat != "" {
+		if err := opts.SetCompat(*compat); err != nil {
+			errAndExit(err)
+		}
+		if *charset != "" {
+			opts.Graphics = graphics
+		}
+	}
+	if *lowPrio {
+		if err := lowerPriority(); err != nil {
+			msgs.Fprintf(os.Stderr, "tree: can't lower the priority: %s\n", err)
+		}
+	}
+	if *explain != "" {
+		explainAndExit(opts, tfs, dirs, *explain)
+	}
+	roots := make([]string, len(dirs))
+	for i, dir := range dirs {
+		// GNU tree shows the paths as given
+		if d, e := tree.NormPath(dir); e == nil && opts.Compat == "" {
+			dir = d
+		}
+		if err := tfs.mount(dir); err != nil {
+			msgs.Fprintf(os.Stderr, "tree: \"%s\": %s\n", dir, err)
+		}
+		roots[i] = dir
+	}
+	if *pagerMode != "never" && *o == "" && !*watchMode &&
+		terminal.IsTerminal(int(os.Stdout.Fd())) {
+		w, err := startPager(*pagerMode)
+		if err != nil {
+			msgs.Fprintf(os.Stderr, "tree: can't run the pager: %s\n", err)
+		} else if w != nil {
+			outFile, opts.OutFile = w, w
+		}
+	}
+	if ohash != nil {
+		opts.OutFile = ohash.writer(opts.OutFile)
+	}
+	var pline *progressLine
+	if *progress && terminal.IsTerminal(int(os.Stderr.Fd())) {
+		pline = &progressLine{Writer: opts.OutFile}
+		opts.OutFile = pline
+		opts.Progress = pline.progress
+	}
+	conf := tree.RunConfig{
+		Options:    opts,
+		Paths:      roots,
+		Format:     oformat,
+		OutputName: *o,
+		Partial:    true,
+	}
+	if *watchMode {
+		if *o != "" {
+			errAndExit(errors.New(msgs.Sprintf("--watch needs the output on stdout")))
+		}
+		if ohash != nil {
+			errAndExit(errors.New(msgs.Sprintf("--watch can't be used with --output-hash")))
+		}
+		if err := watch(conf); err != nil {
+			errAndExit(err)
+		}
+		return
+	}
+	ctx := handleSignals(opts)
+	sum, err := tree.Run(ctx, conf)
+	if pline != nil {
+		pline.clear()
+	}
+	if err != nil {
+		errAndExit(err)
+	}
+	if ohash != nil {
+		if err := ohash.finish(outFile); err != nil {
+			errAndExit(err)
+		}
+	}
+	if err := closeOutput(outFile, *o); err != nil {
+		errAndExit(err)
 	}
+	if ohash != nil {
+		if err := ohash.save(*o); err != nil {
+			errAndExit(err)
+		}
+	}
+	stopPager()
+	if sum.NeedsPrivileges() {
+		msgs.Fprintf(os.Stderr, "tree: %d entries unreadable (permission denied), "+
+			"run with elevated privileges for complete results\n", sum.Denied)
+	}
+	if sum.Incomplete {
+		os.Exit(130)
+	}
+	if sum.Errors > 0 || sum.Violations > 0 {
+		os.Exit(1)
+	}
+}
+
+// progressLine shows the progress of the listing on stderr, until the output
+// starts.
+type progressLine struct {
+	io.Writer
+	mu   sync.Mutex
+	done bool
+	spin int
+}
+
+func (p *progressLine) progress(dirs, files int64, path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	line := []rune(msgs.Sprintf("%c %d dirs, %d files: %s",
+		`|/-\`[p.spin%4], dirs, files, path))
+	if len(line) > 79 {
+		line = append(line[:76], []rune("...")...)
+	}
+	p.spin++
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s", string(line))
+}
+
+// clear removes the progress line, and stops any more being shown.
+func (p *progressLine) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.done && p.spin > 0 {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+	}
+	p.done = true
+}
+
+func (p *progressLine) Write(data []byte) (int, error) {
+	p.clear()
+	return p.Writer.Write(data)
+}
+
+// explainAndExit prints why the path would be hidden under the roots.
+func explainAndExit(opts *tree.Options, tfs *fs, dirs []string, path string) {
+	apath, err := filepath.Abs(path)
+	if err != nil {
+		errAndExit(err)
+	}
+	err = errors.New(msgs.Sprintf("%s is not under any of: %s", path, strings.Join(dirs, " ")))
 	for _, dir := range dirs {
-		if d, e := normPath(dir); e == nil {
+		if d, e := tree.NormPath(dir); e == nil {
 			dir = d
 		}
-		inf := tree.New(dir)
-		d, f := inf.Visit(opts)
-		nd, nf = nd+d, nf+f
-		nsize := tree.NodeSize(inf)
-		ns += nsize
-		inf.Print(opts)
-	}
-	// Print footer report
-	if !*noreport {
-		p := message.NewPrinter(language.Make(os.Getenv("LANG")))
-
-		footer := p.Sprintf("\n%d directories", nd)
-		if !opts.DirsOnly {
-			footer += p.Sprintf(", %d files", nf)
-		}
-		showSize := opts.UnitSize || opts.ByteSize
-		if showSize {
-			if opts.UnitSize {
-				footer += fmt.Sprintf(", %s size", tree.FormatSize(opts, ns))
-			} else {
-				footer += p.Sprintf(", %d size", ns)
-			}
+		if !strings.HasPrefix(apath, dir) {
+			continue
 		}
-		fmt.Fprintln(outFile, footer)
+		tfs.mount(dir)
+		why, e := tree.Explain(opts, dir, apath)
+		if e != nil {
+			err = e
+			continue
+		}
+		if why == "" {
+			msgs.Fprintf(opts.OutFile, "%s: shown\n", path)
+		} else {
+			msgs.Fprintf(opts.OutFile, "%s: hidden by %s\n", path, why)
+		}
+		if err := closeOutput(opts.OutFile.(*os.File), *o); err != nil {
+			errAndExit(err)
+		}
+		os.Exit(0)
+	}
+	errAndExit(err)
+}
+
+// treeIgnoreFile returns the path of the global ignore file, ~/.treeignore
+// if the file isn't given.
+func treeIgnoreFile(file string) string {
+	if file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, tree.TreeIgnoreName)
+}
+
+// gitExcludesFile returns the path of the global git excludes file, from
+// core.excludesFile in ~/.gitconfig or the XDG default.
+func gitExcludesFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	var section string
+	data, _ := ioutil.ReadFile(filepath.Join(home, ".gitconfig"))
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if section != "core" || len(kv) != 2 ||
+			!strings.EqualFold(strings.TrimSpace(kv[0]), "excludesfile") {
+			continue
+		}
+		path := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		if strings.HasPrefix(path, "~/") {
+			path = filepath.Join(home, path[2:])
+		}
+		return path
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	return filepath.Join(home, ".config", "git", "ignore")
+}
+
+// closeOutput closes the output file, and moves the temp. file into place.
+func closeOutput(outFile *os.File, name string) error {
+	if outFile == os.Stdout {
+		return nil
 	}
+	if err := outFile.Close(); err != nil {
+		return err
+	}
+	if tmpOutput == "" {
+		return nil
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := os.Chmod(tmpOutput, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpOutput, name); err != nil {
+		return err
+	}
+	tmpOutput = ""
+	return nil
 }
 
 func usageAndExit(msg string) {
@@ -284,6 +1114,10 @@ func usageAndExit(msg string) {
 }
 
 func errAndExit(err error) {
-	fmt.Fprintf(os.Stderr, "tree: \"%s\"\n", err)
+	if tmpOutput != "" {
+		os.Remove(tmpOutput)
+	}
+	stopPager()
+	msgs.Fprintf(os.Stderr, "tree: \"%s\"\n", err)
 	os.Exit(1)
 }
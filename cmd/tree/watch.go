@@ -0,0 +1,192 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly || solaris || windows
+// +build linux darwin freebsd openbsd netbsd dragonfly solaris windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/james-antill/tree"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// watchDelay batches the changes, so a burst of them is one re-render
+const watchDelay = 200 * time.Millisecond
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watcher is the state of --watch, the dirs. watched are the ones listed.
+type watcher struct {
+	w    *fsnotify.Watcher
+	conf tree.RunConfig
+
+	mu    sync.Mutex
+	roots []*tree.Node // From the last listing
+
+	dirs    map[string]bool // Watched
+	entries map[string]bool // Shown in the last listing
+	order   []string        // The entries, in the order of the listing
+}
+
+// newWatcher keeps the roots of each listing, with a VisitWrapper.
+func newWatcher(conf tree.RunConfig) (*watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	wt := &watcher{w: w, conf: conf, dirs: make(map[string]bool)}
+	opts := conf.Options
+	prev := opts.VisitWrapper
+	opts.VisitWrapper = func(next tree.VisitFn) tree.VisitFn {
+		if prev != nil {
+			next = prev(next)
+		}
+		return func(opts *tree.Options, node *tree.Node) (int, int, error) {
+			if node.Depth() == 0 {
+				wt.mu.Lock()
+				wt.roots = append(wt.roots, node)
+				wt.mu.Unlock()
+			}
+			return next(opts, node)
+		}
+	}
+	return wt, nil
+}
+
+// list prints the trees to out, and updates the entries shown and the
+// dirs. watched.
+func (wt *watcher) list(out io.Writer) error {
+	opts := wt.conf.Options
+	wt.roots = nil
+	stdout := opts.OutFile
+	opts.OutFile = out
+	_, err := tree.Run(context.Background(), wt.conf)
+	opts.OutFile = stdout
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]bool)
+	dirs := make(map[string]bool)
+	wt.order = nil
+	for _, root := range wt.roots {
+		dirs[root.Path()] = true // Even a file, like an archive
+		root.WalkSorted(opts, func(n *tree.Node) error {
+			if opts.DeepLevel > 0 && n.Depth() > opts.DeepLevel {
+				return tree.SkipNode // Only visited for the sizes
+			}
+			entries[n.Path()] = true
+			wt.order = append(wt.order, n.Path())
+			if n.IsDir() && !n.IsVirtual() && n.Err() == nil &&
+				(opts.DeepLevel == 0 || n.Depth() < opts.DeepLevel) {
+				dirs[n.Path()] = true
+			}
+			return nil
+		})
+	}
+	wt.entries = entries
+
+	for dir := range dirs {
+		if wt.dirs[dir] {
+			continue
+		}
+		if err := wt.w.Add(dir); err != nil {
+			msgs.Fprintf(os.Stderr, "tree: \"%s\": %s\n", dir, err)
+			continue
+		}
+		wt.dirs[dir] = true
+	}
+	for dir := range wt.dirs {
+		if !dirs[dir] {
+			wt.w.Remove(dir)
+			delete(wt.dirs, dir)
+		}
+	}
+	return nil
+}
+
+// printChanges lists the trees again, and prints the entries removed (-)
+// since the last listing, then the ones created (+) and modified (~), in
+// the order of the listings.
+func (wt *watcher) printChanges(modified map[string]bool) error {
+	prev, prevOrder := wt.entries, wt.order
+	if err := wt.list(ioutil.Discard); err != nil {
+		return err
+	}
+	out := wt.conf.Options.OutFile
+	for _, path := range prevOrder {
+		if !wt.entries[path] {
+			fmt.Fprintln(out, "- "+path)
+		}
+	}
+	for _, path := range wt.order {
+		switch {
+		case !prev[path]:
+			fmt.Fprintln(out, "+ "+path)
+		case modified[path]:
+			fmt.Fprintln(out, "~ "+path)
+		}
+	}
+	return nil
+}
+
+// watch prints the trees, and then prints them again when anything shown in
+// them is created, removed or modified, until it's killed. When the output
+// isn't a terminal, the changes are printed as lines (+ path, - path, or
+// ~ path) instead.
+func watch(conf tree.RunConfig) error {
+	wt, err := newWatcher(conf)
+	if err != nil {
+		return err
+	}
+	defer wt.w.Close()
+
+	opts := conf.Options
+	live := terminal.IsTerminal(int(os.Stdout.Fd()))
+	if live {
+		fmt.Fprint(opts.OutFile, clearScreen)
+	}
+	if err := wt.list(opts.OutFile); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	modified := make(map[string]bool)
+	for {
+		select {
+		case ev, ok := <-wt.w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Chmod) != 0 {
+				modified[ev.Name] = true
+			}
+			timer.Reset(watchDelay)
+		case err, ok := <-wt.w.Errors:
+			if !ok {
+				return nil
+			}
+			msgs.Fprintf(os.Stderr, "tree: \"%s\"\n", err)
+		case <-timer.C:
+			if !live {
+				err = wt.printChanges(modified)
+			} else {
+				fmt.Fprint(opts.OutFile, clearScreen)
+				err = wt.list(opts.OutFile)
+			}
+			if err != nil {
+				return err
+			}
+			modified = make(map[string]bool)
+		}
+	}
+}
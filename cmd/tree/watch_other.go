@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd && !dragonfly && !solaris && !windows
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd,!dragonfly,!solaris,!windows
+
+package main
+
+import (
+	"errors"
+
+	"github.com/james-antill/tree"
+)
+
+// watch isn't supported, there's no fsnotify.
+func watch(conf tree.RunConfig) error {
+	return errors.New(msgs.Sprintf("not supported on this OS"))
+}
@@ -2,9 +2,11 @@ package tree
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 const Escape = "\x1b"
@@ -147,42 +149,116 @@ var cAudios = []string{
 	".xspf",
 }
 
-// ANSIColor
-func ANSIColor(node *Node, s string) string {
-	var style string
-	var mode = node.Mode()
+// colorClass returns the dircolors category name for the node, or "" when
+// the node isn't colored.
+func colorClass(node *Node) string {
 	var ext = filepath.Ext(node.Name())
 	switch {
 	case contains([]string{".bat", ".btm", ".cmd", ".com", ".dll", ".exe"}, ext):
-		style = "1;32"
+		return "exec"
 	case contains(cArchivesOrCompressed, ext):
-		style = "1;31"
+		return "archive"
 	case contains(cImages, ext):
-		style = "1;35"
+		return "image"
 	case contains(cAudios, ext):
-		style = "1;36"
+		return "audio"
+	}
+	return modeClass(node)
+}
+
+// modeClass returns the colorClass category from the type of the node, or ""
+// for a regular file.
+func modeClass(node *Node) string {
+	var mode = node.Mode()
+	switch {
 	case node.IsDir() || mode&os.ModeDir != 0:
-		style = "1;34"
+		return "dir"
 	case mode&os.ModeNamedPipe != 0:
-		style = "40;33"
+		return "fifo"
 	case mode&os.ModeSocket != 0:
-		style = "40;1;35"
+		return "socket"
 	case mode&os.ModeDevice != 0 || mode&os.ModeCharDevice != 0:
-		style = "40;1;33"
+		return "device"
 	case mode&os.ModeSymlink != 0:
 		if _, err := filepath.EvalSymlinks(node.path); err != nil {
-			style = "40;1;31"
-		} else {
-			style = "1;36"
+			return "orphan"
 		}
+		return "symlink"
 	case mode&modeExecute != 0:
-		style = "1;32"
-	default:
+		return "exec"
+	case node.ctype == contentBinary:
+		return "binary"
+	}
+	return ""
+}
+
+// ansiStyles maps the colorClass categories to ANSI SGR styles.
+var ansiStyles = map[string]string{
+	"exec":    "1;32",
+	"archive": "1;31",
+	"image":   "1;35",
+	"audio":   "1;36",
+	"dir":     "1;34",
+	"fifo":    "40;33",
+	"socket":  "40;1;35",
+	"device":  "40;1;33",
+	"orphan":  "40;1;31",
+	"symlink": "1;36",
+	"binary":  "33",
+}
+
+// ANSIColor
+func ANSIColor(node *Node, s string) string {
+	style, ok := ansiStyles[colorClass(node)]
+	if !ok {
 		return s
 	}
 	return fmt.Sprintf("%s[%sm%s%s[%dm", Escape, style, s, Escape, Reset)
 }
 
+// colorize returns s in the ANSI style for the node, from the AgeBuckets or
+// DirColors if there are any.
+func (opts *Options) colorize(node *Node, s string) string {
+	if ab := opts.AgeBuckets; ab != nil {
+		mtime := node.ModTime()
+		if opts.MtimeRollup {
+			mtime = NewestModTime(node)
+		}
+		return ab.Color(ab.Bucket(mtime), s)
+	}
+	if opts.DirColors != nil {
+		return opts.DirColors.Color(node, s)
+	}
+	return ANSIColor(node, s)
+}
+
+// hostname is for the file:// URLs, "" if it isn't known.
+var hostname = struct {
+	once sync.Once
+	name string
+}{}
+
+// hyperlink returns s as an OSC 8 terminal hyperlink to the file at the path.
+func hyperlink(path, s string) string {
+	hostname.once.Do(func() { hostname.name, _ = os.Hostname() })
+	if apath, err := filepath.Abs(path); err == nil {
+		path = apath
+	}
+	u := url.URL{Scheme: "file", Host: hostname.name, Path: filepath.ToSlash(path)}
+	return fmt.Sprintf("%s]8;;%s%s\\%s%s]8;;%s\\", Escape, u.String(), Escape, s,
+		Escape, Escape)
+}
+
+// HTMLColor wraps the already escaped s in a span, with a CSS class matching
+// the ANSIColor category.
+func HTMLColor(node *Node, s string) string {
+	class := colorClass(node)
+	if class == "" {
+		return s
+	}
+	return fmt.Sprintf("<span class=\"%s\">%s</span>", class, s)
+}
+
 // case-insensitive contains helper
 func contains(slice []string, str string) bool {
 	for _, val := range slice {
@@ -192,5 +268,3 @@ func contains(slice []string, str string) bool {
 	}
 	return false
 }
-
-// TODO: HTMLColor
@@ -2,6 +2,7 @@ package tree
 
 import (
 	"os"
+	"strings"
 	"syscall"
 	"testing"
 )
@@ -51,3 +52,68 @@ func TestFileMode(t *testing.T) {
 		}
 	}
 }
+
+func TestHTMLColor(t *testing.T) {
+	for _, test := range modeTests {
+		fi := &file{name: test.name, mode: test.mode}
+		no := &Node{FileInfo: fi, path: test.path}
+		class := colorClass(no)
+		expected := test.name
+		if class != "" {
+			expected = "<span class=\"" + class + "\">" + test.name + "</span>"
+		}
+		if actual := HTMLColor(no, fi.name); actual != expected {
+			t.Errorf("\ngot:\n%+v\nexpected:\n%+v", actual, expected)
+		}
+	}
+}
+
+func TestDirColors(t *testing.T) {
+	db := `# Like ~/.dir_colors
+TERM xterm*
+DIR 01;33 # yellow
+NORMAL 00
+OPTIONS -F -T 0
+*.JPG 00;32
+.log 35
+*.gz 31
+*.tar.gz 01;31
+*~ 02
+*README 04
+`
+	dc, err := ParseDirColors(strings.NewReader(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		mode     os.FileMode
+		expected string
+	}{
+		{"dir", os.ModeDir, "\x1b[01;33mdir\x1b[0m"},
+		{"a.jpg", 0, "\x1b[00;32ma.jpg\x1b[0m"},
+		{"b.log", 0, "\x1b[35mb.log\x1b[0m"},
+		{"c.tar", 0, "c.tar"}, // NORMAL, not the default archive style
+		{"d.gz", 0, "\x1b[31md.gz\x1b[0m"},
+		{"e.tar.gz", 0, "\x1b[01;31me.tar.gz\x1b[0m"},
+		{"f.c~", 0, "\x1b[02mf.c~\x1b[0m"},
+		{"README", 0, "\x1b[04mREADME\x1b[0m"},
+		{"fifo", os.ModeNamedPipe, "\x1b[40;33mfifo\x1b[0m"},
+	} {
+		no := &Node{FileInfo: &file{name: test.name, mode: test.mode}}
+		if actual := dc.Color(no, test.name); actual != test.expected {
+			t.Errorf("%s:\ngot:\n%q\nexpected:\n%q", test.name, actual, test.expected)
+		}
+	}
+	if _, err := ParseDirColors(strings.NewReader("DIR\n")); err == nil {
+		t.Errorf("expected an error for a missing style")
+	}
+}
+
+func TestHyperlink(t *testing.T) {
+	host, _ := os.Hostname()
+	expected := "\x1b]8;;file://" + host + "/tmp/a%20b\x1b\\a b\x1b]8;;\x1b\\"
+	if actual := hyperlink("/tmp/a b", "a b"); actual != expected {
+		t.Errorf("\ngot:\n%q\nexpected:\n%q", actual, expected)
+	}
+}
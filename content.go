@@ -0,0 +1,93 @@
+package tree
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// OpenFs is an optional interface for a Fs, it's needed for the options that
+// look at the content of files.
+type OpenFs interface {
+	Open(path string) (io.ReadCloser, error)
+}
+
+// contentType is the result of looking at the start of a file
+type contentType int
+
+const (
+	contentUnknown contentType = iota
+	contentText
+	contentBinary
+)
+
+func (c contentType) String() string {
+	switch c {
+	case contentText:
+		return "text"
+	case contentBinary:
+		return "bin "
+	}
+	return "    "
+}
+
+// contentSniffLen is how much of a file is looked at for a NUL byte
+const contentSniffLen = 8 * 1024
+
+// isBinary does the cheap check that git/grep do, a NUL in the first 8K.
+func isBinary(r io.Reader) (bool, error) {
+	buf := make([]byte, contentSniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) != -1, nil
+}
+
+// detectContent returns the content type for the file at path.
+func detectContent(opts *Options, path string) contentType {
+	ofs, ok := opts.Fs.(OpenFs)
+	if !ok {
+		return contentUnknown
+	}
+	f, err := ofs.Open(path)
+	if err != nil {
+		return contentUnknown
+	}
+	defer f.Close()
+
+	bin, err := isBinary(f)
+	switch {
+	case err != nil:
+		return contentUnknown
+	case bin:
+		return contentBinary
+	}
+	return contentText
+}
+
+// contentSkipped returns true if the content of the file shouldn't be read,
+// because it's too big or the name matches one of Options.NoContent.
+func contentSkipped(opts *Options, fi os.FileInfo) bool {
+	if opts.ContentMaxSize > 0 && fi.Size() > opts.ContentMaxSize {
+		return true
+	}
+	for _, pat := range opts.NoContent {
+		if ok, _ := filepath.Match(pat, fi.Name()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContent sets the content type of the node, if it's needed.
+func (node *Node) checkContent(opts *Options) {
+	if !node.Mode().IsRegular() || !(opts.ShowContent || opts.Content != "") {
+		return
+	}
+	if contentSkipped(opts, node) {
+		return
+	}
+	node.ctype = detectContent(opts, node.path)
+}
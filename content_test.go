@@ -0,0 +1,86 @@
+package tree
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsBinary(t *testing.T) {
+	data := []struct {
+		val string
+		res bool
+	}{
+		{"", false},
+		{"hello world\n", false},
+		{"ELF\x00\x01\x02", true},
+		{strings.Repeat("a", contentSniffLen) + "\x00", false},
+	}
+
+	for i := range data {
+		bin, err := isBinary(strings.NewReader(data[i].val))
+		if err != nil {
+			t.Errorf("data %v: unexpected error: %v", i, err)
+		}
+		if bin != data[i].res {
+			t.Errorf("data not equal: %v: got %v expected %v", i, bin, data[i].res)
+		}
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	data := []struct {
+		hash, val, sum string
+	}{
+		{"xxh64", "", "ef46db3751d8e999"},
+		{"xxh64", "a", "d24ec4f1a98c6e5b"},
+		{"xxh64", "abc", "44bc2cf5ad770999"},
+		{"xxh64", "asdf", "415872f599cea71e"},
+		{"xxh64", "Call me Ishmael. Some years ago--never mind how long precisely-", "02a2e85470d6fd96"},
+		{"md5", "abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{"sha256", "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for i := range data {
+		h := checksums[data[i].hash]()
+		io.WriteString(h, data[i].val)
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != data[i].sum {
+			t.Errorf("data %v: %s got %s expected %s", i, data[i].hash, sum, data[i].sum)
+		}
+		// The same, written a byte at a time
+		h.Reset()
+		for val := data[i].val; val != ""; val = val[1:] {
+			io.WriteString(h, val[:1])
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != data[i].sum {
+			t.Errorf("data %v: %s in parts got %s expected %s", i, data[i].hash, sum, data[i].sum)
+		}
+	}
+}
+
+func TestContentSkipped(t *testing.T) {
+	data := []struct {
+		name    string
+		size    int64
+		maxSize int64
+		skip    []string
+		res     bool
+	}{
+		{"a.txt", 100, 0, nil, false},
+		{"a.txt", 100, 100, nil, false},
+		{"a.txt", 101, 100, nil, true},
+		{"a.iso", 1, 0, []string{"*.img", "*.iso"}, true},
+		{"a.iso.txt", 1, 0, []string{"*.iso"}, false},
+		{"a.iso", 1, 0, []string{"[a.iso"}, false}, // A bad pattern
+	}
+
+	for i := range data {
+		opts := &Options{ContentMaxSize: data[i].maxSize, NoContent: data[i].skip}
+		fi := VirtualInfo(data[i].name, data[i].size, 0644, time.Time{})
+		if skip := contentSkipped(opts, fi); skip != data[i].res {
+			t.Errorf("data %v: got %v expected %v", i, skip, data[i].res)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tree
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// csvColumns are the columns of the CSV/TSV output
+var csvColumns = []string{
+	"path", "depth", "type", "size", "mode", "uid", "gid", "mtime", "inode",
+}
+
+// newCSVWriter returns a csv.Writer using a tab for TSV output
+func newCSVWriter(opts *Options, w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if opts.TSV {
+		cw.Comma = '\t'
+	}
+	return cw
+}
+
+// CSVHeader writes the header row for the output of Print when Options.CSV
+// or Options.TSV is set.
+func CSVHeader(opts *Options, w io.Writer) {
+	cw := newCSVWriter(opts, w)
+	cw.Write(csvColumns)
+	cw.Flush()
+}
+
+// nodeType returns a simple name for the type of the node
+func nodeType(node *Node) string {
+	var mode = node.Mode()
+	switch {
+	case node.err != nil:
+		return "error"
+	case node.IsDir():
+		return "dir"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeDevice != 0 || mode&os.ModeCharDevice != 0:
+		return "device"
+	}
+	return "file"
+}
+
+// printCSV writes a row for the node, and then all it's children.
+func (node *Node) printCSV(cw *csv.Writer, opts *Options) {
+	row := []string{node.path, strconv.Itoa(node.depth), nodeType(node)}
+	if node.err != nil {
+		row = append(row, "", "", "", "", "", "")
+	} else {
+		var uid, gid, inode string
+		if ok, ino, _, u, g := getStat(node); ok {
+			uid = strconv.FormatUint(u, 10)
+			gid = strconv.FormatUint(g, 10)
+			inode = strconv.FormatUint(ino, 10)
+		}
+		row = append(row,
+			strconv.FormatInt(NodeSize(node), 10),
+			node.Mode().String(),
+			uid, gid,
+			node.ModTime().Format(time.RFC3339),
+			inode)
+	}
+	cw.Write(row)
+
+	if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
+		return
+	}
+	for _, nnode := range node.sortedNodes(opts) {
+		nnode.printCSV(cw, opts)
+	}
+}
@@ -0,0 +1,115 @@
+package tree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DirColors is a dircolors(1) database, for Options.DirColors. The styles
+// are the ANSI SGR parameters, like "01;34".
+type DirColors struct {
+	classes  map[string]string // modeClass categories
+	suffixes []dirColorsSuffix // Lower case, the longest first
+}
+
+// dirColorsSuffix is the style for the names ending in the suffix, like
+// "*.tar.gz" or "*~" in the database.
+type dirColorsSuffix struct {
+	suffix string
+	style  string
+}
+
+// dirColorsKeywords map the dircolors keywords to the modeClass categories.
+// The keywords for things tree doesn't show (like SETUID) aren't used.
+var dirColorsKeywords = map[string]string{
+	"NORMAL":  "",
+	"FILE":    "",
+	"DIR":     "dir",
+	"LINK":    "symlink",
+	"SYMLINK": "symlink",
+	"ORPHAN":  "orphan",
+	"FIFO":    "fifo",
+	"PIPE":    "fifo",
+	"SOCK":    "socket",
+	"BLK":     "device",
+	"BLOCK":   "device",
+	"CHR":     "device",
+	"CHAR":    "device",
+	"EXEC":    "exec",
+}
+
+// ParseDirColors reads a dircolors database, like ~/.dir_colors. The TERM
+// lines are ignored, so all the entries are used, and so are the keywords
+// tree doesn't use (like OPTIONS).
+func ParseDirColors(r io.Reader) (*DirColors, error) {
+	dc := &DirColors{classes: make(map[string]string)}
+	suffixes := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		key := fields[0]
+		class, ok := dirColorsKeywords[strings.ToUpper(key)]
+		suffix := strings.HasPrefix(key, "*") || strings.HasPrefix(key, ".")
+		if !ok && !suffix {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("dircolors line %d: expected a keyword and a style: %q",
+				num, scanner.Text())
+		}
+		if suffix {
+			suffixes[strings.ToLower(strings.TrimPrefix(key, "*"))] = fields[1]
+		} else {
+			dc.classes[class] = fields[1]
+		}
+	}
+	for suffix, style := range suffixes {
+		dc.suffixes = append(dc.suffixes, dirColorsSuffix{suffix, style})
+	}
+	sort.Slice(dc.suffixes, func(i, j int) bool {
+		si, sj := dc.suffixes[i].suffix, dc.suffixes[j].suffix
+		if len(si) != len(sj) {
+			return len(si) > len(sj)
+		}
+		return si < sj
+	})
+	return dc, scanner.Err()
+}
+
+// style returns the style for the node, files by the longest suffix of the
+// name and then all the nodes by type. The types not in the database use the
+// default styles.
+func (dc *DirColors) style(node *Node) string {
+	class := modeClass(node)
+	if !node.IsDir() {
+		name := strings.ToLower(node.Name())
+		for _, s := range dc.suffixes {
+			if strings.HasSuffix(name, s.suffix) {
+				return s.style
+			}
+		}
+	}
+	if style, ok := dc.classes[class]; ok {
+		return style
+	}
+	return ansiStyles[class]
+}
+
+// Color returns s in the ANSI style for the node, like ANSIColor.
+func (dc *DirColors) Color(node *Node, s string) string {
+	style := dc.style(node)
+	if strings.Trim(style, "0;") == "" { // No style, or the reset
+		return s
+	}
+	return fmt.Sprintf("%s[%sm%s%s[%dm", Escape, style, s, Escape, Reset)
+}
@@ -0,0 +1,281 @@
+package tree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The filters return why an entry is hidden, or "" if it isn't, so that
+// Explain can use the same rules as Visit.
+
+// DefaultSkipSuffixes are the suffixes of the backup files that are hidden,
+// when Options.SkipSuffixes is nil.
+var DefaultSkipSuffixes = []string{"~", ".bak"}
+
+// skipName returns why the entry is hidden, just from it's name.
+func skipName(opts *Options, name string) string {
+	// "all" option
+	if !opts.All && strings.HasPrefix(name, ".") {
+		return "dotfile default (use -a)"
+	}
+	suffixes := opts.SkipSuffixes
+	if suffixes == nil {
+		suffixes = DefaultSkipSuffixes
+	}
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Sprintf("backup file (*%s)", suffix)
+		}
+	}
+	return ""
+}
+
+// EntryType is a set of the types of entries, for Options.Types.
+type EntryType int
+
+const (
+	TypeFile    EntryType = 1 << iota // Regular files
+	TypeDir                           // Directories
+	TypeSymlink                       // Symbolic links
+	TypeExec                          // Executable regular files
+)
+
+// entryTypeLetters are the letters for the types, like find -type
+var entryTypeLetters = []struct {
+	letter byte
+	etype  EntryType
+}{
+	{'f', TypeFile}, {'d', TypeDir}, {'l', TypeSymlink}, {'x', TypeExec},
+}
+
+// ParseTypes converts the letters f, d, l and x (like find -type) to the
+// types, commas are ignored so "f,l" and "fl" are the same.
+func ParseTypes(s string) (EntryType, error) {
+	var ret EntryType
+next:
+	for i := 0; i < len(s); i++ {
+		if s[i] == ',' {
+			continue
+		}
+		for _, t := range entryTypeLetters {
+			if s[i] == t.letter {
+				ret |= t.etype
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("type '%c' not valid, should be one of: f,d,l,x", s[i])
+	}
+	return ret, nil
+}
+
+// String returns the letters for the types, like "fl".
+func (etype EntryType) String() string {
+	var ret []byte
+	for _, t := range entryTypeLetters {
+		if etype&t.etype != 0 {
+			ret = append(ret, t.letter)
+		}
+	}
+	return string(ret)
+}
+
+// matchTypes returns if the file (not a dir.) is one of the types, dirs.
+// are always shown for the tree structure.
+func matchTypes(etype EntryType, node *Node) bool {
+	mode := node.Mode()
+	switch {
+	case etype&TypeFile != 0 && mode.IsRegular():
+		return true
+	case etype&TypeSymlink != 0 && mode&os.ModeSymlink != 0:
+		return true
+	case etype&TypeExec != 0 && mode.IsRegular() && mode&0111 != 0:
+		return true
+	}
+	return false
+}
+
+// skipFile returns why the file is hidden, after it's been stat'd.
+func skipFile(opts *Options, node *Node) string {
+	// "dirs only" option
+	if opts.DirsOnly {
+		return "dirs only (-d)"
+	}
+	// "links only" option
+	if opts.LinksOnly && node.Mode()&os.ModeSymlink == 0 {
+		return "links only (--links-only)"
+	}
+	// entry type option
+	if opts.Types != 0 && !matchTypes(opts.Types, node) {
+		return fmt.Sprintf("type filter (--type %s)", opts.Types)
+	}
+	// Owner options
+	if opts.User != "" || opts.Group != "" {
+		ok, _, _, uid, gid := getStat(node)
+		if opts.User != "" && !(ok && matchID(uid, uidConvert(uid, true), opts.User)) {
+			return fmt.Sprintf("not owned by user (--user %s)", opts.User)
+		}
+		if opts.Group != "" && !(ok && matchID(gid, gidConvert(gid, true), opts.Group)) {
+			return fmt.Sprintf("not owned by group (--group %s)", opts.Group)
+		}
+	}
+	// content type option
+	switch {
+	case opts.Content == "text" && node.ctype != contentText:
+		return "type filter (--type text)"
+	case opts.Content == "binary" && node.ctype != contentBinary:
+		return "type filter (--type binary)"
+	}
+	// Pattern matching
+	if opts.Pattern != "" {
+		match, err := patternMatch(opts, opts.Pattern, node)
+		if err == nil && !match {
+			return fmt.Sprintf("not matching pattern (-P %s)", opts.Pattern)
+		}
+	}
+	// IPattern matching
+	if opts.IPattern != "" {
+		match, err := patternMatch(opts, opts.IPattern, node)
+		if err == nil && match {
+			return fmt.Sprintf("matching ignore pattern (-I %s)", opts.IPattern)
+		}
+	}
+	// Modification time
+	if !opts.Newer.IsZero() && !node.ModTime().After(opts.Newer) {
+		return fmt.Sprintf("not newer than %s (--newer)", opts.Newer.Format(isoTimeLayout))
+	}
+	if !opts.Older.IsZero() && !node.ModTime().Before(opts.Older) {
+		return fmt.Sprintf("not older than %s (--older)", opts.Older.Format(isoTimeLayout))
+	}
+	// Where expression
+	if opts.Where != nil && !opts.Where.Match(node) {
+		return fmt.Sprintf("not matching expression (--where %s)", opts.Where)
+	}
+	return ""
+}
+
+// patternMatch returns if the file matches the pattern, a regexp for the
+// name or with Options.Glob globs separated by | (see globExpr) for the path
+// from the root.
+func patternMatch(opts *Options, pattern string, node *Node) (bool, error) {
+	var rePrefix string
+	if opts.IgnoreCase {
+		rePrefix = "(?i)"
+	}
+	if !opts.Glob {
+		re, err := regexp.Compile(rePrefix + pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(node.Name()), nil
+	}
+
+	path := filepath.ToSlash(node.relPath())
+	for _, pat := range strings.Split(pattern, "|") {
+		expr, err := globExpr(pat)
+		if err != nil {
+			return false, err
+		}
+		re, err := regexp.Compile(rePrefix + expr)
+		if err != nil {
+			return false, err
+		}
+		if re.MatchString(path) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// skipDir returns why the dir. is hidden, after it's been stat'd.
+func skipDir(opts *Options, node *Node) string {
+	if !sampled(opts, node.path) {
+		return fmt.Sprintf("not in the sample (--sample %g%%)", opts.Sample*100)
+	}
+	if opts.ExcludePseudo {
+		if fstype := pseudoFs(node.path); fstype != "" {
+			return fmt.Sprintf("pseudo filesystem %s (--exclude-pseudo)", fstype)
+		}
+	}
+	return ""
+}
+
+// skipIgnored returns why the entry is hidden by a .treeignore or
+// .gitignore file, after it's been stat'd.
+func skipIgnored(opts *Options, node *Node) string {
+	if where := node.ignore.match(node.path, node.IsDir()); where != "" {
+		return fmt.Sprintf("matching ignore file (%s)", where)
+	}
+	return ""
+}
+
+// ignoreError returns if errors for the path aren't shown, because it's
+// under one of the IgnoreErrors paths.
+func ignoreError(opts *Options, path string) bool {
+	path = filepath.Clean(path)
+	sep := string(filepath.Separator)
+	for _, ipath := range opts.IgnoreErrors {
+		ipath = filepath.Clean(ipath)
+		if path == ipath || strings.HasPrefix(path, strings.TrimSuffix(ipath, sep)+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain returns why the path would be hidden in the tree for root, or ""
+// if it would be shown. Dynamic leveling (-L -1) isn't a filter, so it isn't
+// explained.
+func Explain(opts *Options, root, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not under %s", path, root)
+	}
+	if rel == "." {
+		return "", nil
+	}
+
+	node := &Node{path: root}
+	node.ignore = loadIgnores(opts, root, nil, true)
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if node.depth > 0 && !node.IsDir() {
+			return "", fmt.Errorf("%s is not a directory", node.path)
+		}
+		if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
+			return fmt.Sprintf("depth cutoff (-L %d)", opts.DeepLevel), nil
+		}
+		if why := skipName(opts, name); why != "" {
+			return why, nil
+		}
+
+		node = &Node{path: filepath.Join(node.path, name), depth: node.depth + 1,
+			ignore: node.ignore}
+		fi, err := opts.Fs.Stat(node.path)
+		if err != nil {
+			return "", err
+		}
+		node.FileInfo = fi
+		if node.IsDir() {
+			if why := skipDir(opts, node); why != "" {
+				return why, nil
+			}
+		}
+		if why := skipIgnored(opts, node); why != "" {
+			return why, nil
+		}
+		if node.IsDir() {
+			node.ignore = loadIgnores(opts, node.path, node.ignore, false)
+		}
+	}
+
+	if node.IsDir() {
+		return "", nil
+	}
+	node.checkContent(opts)
+	return skipFile(opts, node), nil
+}
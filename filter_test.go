@@ -0,0 +1,97 @@
+package tree
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGlob(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a.go", nil, 0644, testTime).
+		AddFile("root/b.c", nil, 0644, testTime).
+		AddFile("root/c.txt", nil, 0644, testTime).
+		AddFile("root/src/d.go", nil, 0644, testTime).
+		AddFile("root/src/x/e_test.go", nil, 0644, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"pattern", &Options{Glob: true, Pattern: "*.go|*.c"}, `
+root
+┣━ a.go
+┣━ b.c
+┗━ src
+  ┣━ d.go
+  ┗━ x
+    ┗━ e_test.go
+`, 0, 0},
+		{"ignore-pattern", &Options{Glob: true,
+			IPattern: "src/**/*_test.go|*.TXT", IgnoreCase: true}, `
+root
+┣━ a.go
+┣━ b.c
+┗━ src
+  ┣━ d.go
+  ┗━ x
+`, 0, 0},
+	})
+}
+
+func TestLinksOnly(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a", nil, 0644, testTime).
+		AddSymlink("root/b/c", "../a", testTime).
+		AddFile("root/d/e", nil, 0644, testTime).
+		AddSymlink("root/l", "a", testTime)
+
+	opts := &Options{LinksOnly: true}
+	testMapFs(t, mfs, []treeTest{
+		{"links-only", opts, `
+root
+┣━ b
+┃ ┗━ c -> ../a
+┣━ d
+┗━ l -> a
+`, 0, 0},
+	})
+	if why, _ := Explain(opts, "root", "root/d/e"); why != "links only (--links-only)" {
+		t.Errorf("explain: got %q", why)
+	}
+}
+
+func TestNewerOlder(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a", nil, 0644, testTime).
+		AddFile("root/b", nil, 0644, testTime.Add(24*time.Hour)).
+		AddFile("root/c", nil, 0644, testTime.Add(48*time.Hour))
+
+	testMapFs(t, mfs, []treeTest{
+		{"newer-older", &Options{Newer: testTime,
+			Older: testTime.Add(48 * time.Hour)}, `
+root
+┗━ b
+`, 0, 0},
+	})
+}
+
+func TestTypes(t *testing.T) {
+	types, err := ParseTypes("x,l")
+	if err != nil || types != TypeExec|TypeSymlink || types.String() != "lx" {
+		t.Fatalf("parse: got %v (%v)", types, err)
+	}
+	if _, err := ParseTypes("fz"); err == nil {
+		t.Errorf("parse: expected an error for z")
+	}
+
+	mfs := NewMapFs().
+		AddFile("root/a", nil, 0644, testTime).
+		AddFile("root/b/c", nil, 0755, testTime).
+		AddSymlink("root/d", "a", testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"types", &Options{Types: types}, `
+root
+┣━ b
+┃ ┗━ c
+┗━ d -> a
+`, 0, 0},
+	})
+}
@@ -0,0 +1,147 @@
+package tree
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// ellipsis ends the truncated lines
+const ellipsis = "…"
+
+// runeWidth returns the columns the rune uses in a terminal.
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// escapeLen returns the length of the terminal escape sequence at the start
+// of s (CSI, like colors, or OSC, like hyperlinks), or 0.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != Escape[0] {
+		return 0
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == Escape[0] && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	}
+	return len(s)
+}
+
+// textWidth returns the columns used by s, without the escape sequences.
+func textWidth(s string) int {
+	cols := 0
+	for len(s) > 0 {
+		if n := escapeLen(s); n > 0 {
+			s = s[n:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		cols += runeWidth(r)
+		s = s[size:]
+	}
+	return cols
+}
+
+// cutWidth splits s after cols columns, escape sequences aren't split. The
+// rest starts with the color and hyperlink that are active at the cut, and
+// they're ended in the head.
+func cutWidth(s string, cols int) (head, rest string) {
+	var sgr, link string // Active at the cut
+	i := 0
+	for i < len(s) {
+		if n := escapeLen(s[i:]); n > 0 {
+			seq := s[i : i+n]
+			switch {
+			case strings.HasPrefix(seq, Escape+"]8;"):
+				link = seq
+				if strings.HasPrefix(seq, Escape+"]8;;"+Escape) || seq == Escape+"]8;;\a" {
+					link = ""
+				}
+			case strings.HasSuffix(seq, "m"):
+				sgr = seq
+				if strings.Trim(seq[2:len(seq)-1], "0;") == "" {
+					sgr = ""
+				}
+			}
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if cols -= runeWidth(r); cols < 0 {
+			break
+		}
+		i += size
+	}
+	head, rest = s[:i], s[i:]
+	if rest == "" {
+		return head, rest
+	}
+	if sgr != "" {
+		head += Escape + "[0m"
+		rest = sgr + rest
+	}
+	if link != "" {
+		head += Escape + "]8;;" + Escape + "\\"
+		rest = link + rest
+	}
+	return head, rest
+}
+
+// fitLine returns the line truncated with an ellipsis, or wrapped, to the
+// cols. Wrapped lines are indented under the name, with the guide lines of
+// the tree continued.
+func fitLine(opts *Options, line, indentc string, props []string) string {
+	if opts.Width <= 0 || textWidth(line) <= opts.Width {
+		return line
+	}
+	if !opts.WrapNames {
+		head, _ := cutWidth(line, opts.Width-1)
+		return head + ellipsis
+	}
+
+	g := opts.graphics()
+	indent := strings.Repeat(" ", textWidth(textProps(props)))
+	switch {
+	case strings.HasSuffix(indentc, g.Branch):
+		indent += strings.TrimSuffix(indentc, g.Branch) + g.Vertical
+	case strings.HasSuffix(indentc, g.Last):
+		indent += strings.TrimSuffix(indentc, g.Last) + g.Space
+	default:
+		indent += indentc
+	}
+	// Under the name, the guide can be narrower than the branch
+	if pad := textWidth(textProps(props)) + textWidth(indentc) - textWidth(indent); pad > 0 {
+		indent += strings.Repeat(" ", pad)
+	}
+	cols := opts.Width - textWidth(indent)
+	if cols < 8 { // Too deep to wrap, so just truncate
+		head, _ := cutWidth(line, opts.Width-1)
+		return head + ellipsis
+	}
+
+	head, rest := cutWidth(line, opts.Width)
+	lines := []string{head}
+	for rest != "" {
+		head, rest = cutWidth(rest, cols)
+		lines = append(lines, indent+head)
+	}
+	return strings.Join(lines, "\n")
+}
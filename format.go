@@ -2,7 +2,13 @@ package tree
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
 )
 
 // KB = 1000 bytes
@@ -101,3 +107,271 @@ func formatBytesKiB(i int64) (result string) {
 	result = strings.Trim(result, " ")
 	return
 }
+
+// ParseSize converts a human readable size like 100M or 1.5Gi to bytes. The
+// units are K, M, G, T, P, E (1000 based, as formatBytes) or with an i
+// suffix (1024 based), a trailing B is ignored.
+func ParseSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	mul := int64(1)
+	if strings.HasSuffix(num, "I") && len(num) > 1 {
+		num = num[:len(num)-1]
+		switch num[len(num)-1] {
+		case 'K':
+			mul = KiB
+		case 'M':
+			mul = MiB
+		case 'G':
+			mul = GiB
+		case 'T':
+			mul = TiB
+		case 'P':
+			mul = PiB
+		case 'E':
+			mul = EiB
+		default:
+			return 0, fmt.Errorf("invalid size: %q", s)
+		}
+		num = num[:len(num)-1]
+	} else if len(num) > 0 {
+		switch num[len(num)-1] {
+		case 'K':
+			mul = KB
+		case 'M':
+			mul = MB
+		case 'G':
+			mul = GB
+		case 'T':
+			mul = TB
+		case 'P':
+			mul = PB
+		case 'E':
+			mul = EB
+		}
+		if mul != 1 {
+			num = num[:len(num)-1]
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(f * float64(mul)), nil
+}
+
+// parseTimeLayouts are the absolute dates ParseTime accepts
+var parseTimeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// ParseTime converts a date like 2023-01-01 (in local time) or a duration
+// before now like 7d or 1.5h to a time. The duration units are s, m, h, d
+// and w.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range parseTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	unit := strings.TrimLeft(s, "0123456789.")
+	if dur, ok := whereDurations[unit]; ok && unit != s {
+		num, err := strconv.ParseFloat(strings.TrimSuffix(s, unit), 64)
+		if err == nil {
+			return now.Add(-time.Duration(num * float64(dur))), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %q", s)
+}
+
+// isoTimeLayout is the default layout for the LastMod dates
+const isoTimeLayout = "2006-01-02 15:04"
+
+// timeLayouts are the date layouts for the regions and languages that don't
+// use the ISO order, the region is tried first.
+var timeLayouts = map[string]string{
+	"US": "01/02/2006 15:04",
+
+	"en": "02/01/2006 15:04",
+	"es": "02/01/2006 15:04",
+	"fr": "02/01/2006 15:04",
+	"it": "02/01/2006 15:04",
+	"nl": "02-01-2006 15:04",
+	"pt": "02/01/2006 15:04",
+	"cs": "02.01.2006 15:04",
+	"da": "02.01.2006 15:04",
+	"de": "02.01.2006 15:04",
+	"fi": "02.01.2006 15:04",
+	"nb": "02.01.2006 15:04",
+	"pl": "02.01.2006 15:04",
+	"ru": "02.01.2006 15:04",
+	"tr": "02.01.2006 15:04",
+	"uk": "02.01.2006 15:04",
+	"ja": "2006/01/02 15:04",
+	"ko": "2006. 01. 02. 15:04",
+	"zh": "2006/01/02 15:04",
+}
+
+var (
+	localeLayoutOnce sync.Once
+	localeLayout     string
+)
+
+// localeTimeLayout returns the date layout for the locale, from LC_ALL,
+// LC_TIME or LANG.
+func localeTimeLayout() string {
+	localeLayoutOnce.Do(func() {
+		var lang string
+		for _, env := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+			if lang = os.Getenv(env); lang != "" {
+				break
+			}
+		}
+		localeLayout = timeLayoutFor(lang)
+	})
+	return localeLayout
+}
+
+// timeLayoutFor returns the date layout for the locale name, it's ISO for
+// the C locale or if it isn't known.
+func timeLayoutFor(lang string) string {
+	// Eg. en_GB.UTF-8@euro
+	if i := strings.IndexAny(lang, ".@"); i != -1 {
+		lang = lang[:i]
+	}
+	tag := language.Make(lang)
+	if tag == language.Und {
+		return isoTimeLayout
+	}
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+	if layout, ok := timeLayouts[region.String()]; ok {
+		return layout
+	}
+	if layout, ok := timeLayouts[base.String()]; ok {
+		return layout
+	}
+	return isoTimeLayout
+}
+
+// strftimeLayouts are the Go layouts for the strftime(3) conversions
+var strftimeLayouts = map[byte]string{
+	'a': "Mon", 'A': "Monday", 'b': "Jan", 'h': "Jan", 'B': "January",
+	'd': "02", 'e': "_2", 'j': "002", 'm': "01", 'y': "06", 'Y': "2006",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'z': "-0700", 'Z': "MST",
+	'F': "2006-01-02", 'T': "15:04:05", 'R': "15:04", 'D': "01/02/06",
+}
+
+// strftimeText are the strftime(3) conversions for text
+var strftimeText = map[byte]string{'n': "\n", 't': "\t", '%': "%"}
+
+// ParseTimeFormat checks the format for Options.TimeFormat, a Go layout or
+// a strftime(3) format (with a %), and returns it. iso is for ISO-8601.
+func ParseTimeFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return "", fmt.Errorf("empty time format")
+	case "iso", "iso-8601", "iso8601":
+		return time.RFC3339, nil
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i++; i == len(s) {
+			return "", fmt.Errorf("invalid time format %q: ends with %%", s)
+		}
+		if strftimeLayouts[s[i]] == "" && strftimeText[s[i]] == "" {
+			return "", fmt.Errorf("invalid time format %q: %%%c isn't supported", s, s[i])
+		}
+	}
+	return s, nil
+}
+
+// strftime formats the time with the strftime(3) format, the text between
+// the conversions is copied as is.
+func strftime(t time.Time, format string) string {
+	var buf strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			buf.WriteByte(format[i])
+			continue
+		}
+		i++
+		if layout, ok := strftimeLayouts[format[i]]; ok {
+			buf.WriteString(t.Format(layout))
+		} else if text, ok := strftimeText[format[i]]; ok {
+			buf.WriteString(text)
+		} else {
+			buf.WriteString(format[i-1 : i+1])
+		}
+	}
+	return buf.String()
+}
+
+// relativeTimeWidth is the width of the relative times, the longest is
+// like "11 months ago".
+const relativeTimeWidth = 13
+
+// relativeTime returns the age of the time, like "3h ago" or "2 years ago",
+// right aligned so the ages line up.
+func relativeTime(t, now time.Time) string {
+	plural := func(num int, unit string) string {
+		if num == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", num, unit)
+	}
+	age := now.Sub(t)
+	future := age < 0
+	if future {
+		age = -age
+	}
+	days := int(age / (24 * time.Hour))
+	var ret string
+	switch {
+	case age < time.Minute:
+		ret = fmt.Sprintf("%ds", int(age/time.Second))
+	case age < time.Hour:
+		ret = fmt.Sprintf("%dm", int(age/time.Minute))
+	case days < 1:
+		ret = fmt.Sprintf("%dh", int(age/time.Hour))
+	case days < 60:
+		ret = plural(days, "day")
+	case days < 365:
+		ret = plural(days/30, "month")
+	default:
+		ret = plural(days/365, "year")
+	}
+	if future {
+		ret = "in " + ret
+	} else {
+		ret += " ago"
+	}
+	return fmt.Sprintf("%*s", relativeTimeWidth, ret)
+}
+
+// formatModTime returns the LastMod date, see Options.RelativeTime,
+// Options.TimeFormat and Options.LocaleTime.
+func formatModTime(opts *Options, mtime time.Time) string {
+	if opts.RelativeTime {
+		return relativeTime(mtime, time.Now())
+	}
+	if strings.Contains(opts.TimeFormat, "%") {
+		return strftime(mtime, opts.TimeFormat)
+	}
+	if opts.TimeFormat != "" {
+		return mtime.Format(opts.TimeFormat)
+	}
+	if opts.LocaleTime {
+		return mtime.Format(localeTimeLayout())
+	}
+	return mtime.Format(isoTimeLayout)
+}
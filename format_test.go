@@ -1,7 +1,9 @@
 package tree
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFormatBytes(t *testing.T) {
@@ -103,3 +105,181 @@ func TestFormatBytesKiB(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	data := []struct {
+		val string
+		res int64
+	}{
+		{"0", 0}, // 0
+		{"1", 1},
+		{"999", 999},
+		{"1K", 1000},
+		{"1.5k", 1500},
+		{"100M", 100 * 1000 * 1000},
+		{"2G", 2 * 1000 * 1000 * 1000},
+		{"1Ki", 1024},
+		{"1KiB", 1024},
+		{"3Mi", 3 * 1024 * 1024},
+		{"10MB", 10 * 1000 * 1000},
+	}
+
+	for i := range data {
+		val := data[i].val
+		res := data[i].res
+
+		if tst, err := ParseSize(val); err != nil || tst != res {
+			t.Errorf("data not equal: %v: %v\n tst=<%d>\n got <%d> (%v)\n",
+				i, val, res, tst, err)
+		}
+	}
+
+	for _, val := range []string{"", "abc", "1X", "-1", "1Xi"} {
+		if _, err := ParseSize(val); err == nil {
+			t.Errorf("expected error for: %q", val)
+		}
+	}
+}
+
+func TestTimeLayoutFor(t *testing.T) {
+	data := []struct {
+		lang   string
+		layout string
+	}{
+		{"", "2006-01-02 15:04"},
+		{"C", "2006-01-02 15:04"},
+		{"POSIX", "2006-01-02 15:04"},
+		{"en_US.UTF-8", "01/02/2006 15:04"},
+		{"en_GB.UTF-8", "02/01/2006 15:04"},
+		{"de_DE.UTF-8@euro", "02.01.2006 15:04"},
+		{"ja_JP", "2006/01/02 15:04"},
+		{"sv_SE.UTF-8", "2006-01-02 15:04"},
+	}
+
+	for _, d := range data {
+		if layout := timeLayoutFor(d.lang); layout != d.layout {
+			t.Errorf("%q: got %q expected %q", d.lang, layout, d.layout)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.Local)
+	data := []struct {
+		val string
+		res time.Time
+	}{
+		{"2023-01-01", time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"2023-01-01 10:30", time.Date(2023, 1, 1, 10, 30, 0, 0, time.Local)},
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"1.5h", now.Add(-90 * time.Minute)},
+		{"2w", now.Add(-14 * 24 * time.Hour)},
+	}
+
+	for _, d := range data {
+		if res, err := ParseTime(d.val, now); err != nil || !res.Equal(d.res) {
+			t.Errorf("%q: got %v (%v) expected %v", d.val, res, err, d.res)
+		}
+	}
+
+	for _, val := range []string{"", "d", "7y", "2023-13-01", "yesterday"} {
+		if _, err := ParseTime(val, now); err == nil {
+			t.Errorf("expected error for: %q", val)
+		}
+	}
+}
+
+func TestFitLine(t *testing.T) {
+	for _, test := range []struct {
+		line, indentc string
+		props         []string
+		width         int
+		wrap          bool
+		expected      string
+	}{
+		{"┣━ short", "┣━ ", nil, 10, false, "┣━ short"},
+		{"┣━ longer_name", "┣━ ", nil, 10, false, "┣━ longer…"},
+		{"┣━ 日本語です", "┣━ ", nil, 10, false, "┣━ 日本語…"},
+		{"┣━ longer_name", "┣━ ", nil, 10, true, "┣━ longer…"}, // Too narrow
+		{"┣━ longer_name_here", "┣━ ", nil, 14, true, "┣━ longer_name\n┃  _here"},
+		{"1 ┗━ a_long_name_here", "┗━ ", []string{"1"}, 14, true,
+			"1 ┗━ a_long_na\n     me_here"},
+		{"┗━ \x1b[1;34mlonger_name\x1b[0m", "┗━ ", nil, 10, false,
+			"┗━ \x1b[1;34mlonger\x1b[0m…"},
+		{"┗━ \x1b[1;34mlonger_name_here\x1b[0m", "┗━ ", nil, 14, true,
+			"┗━ \x1b[1;34mlonger_name\x1b[0m\n   \x1b[1;34m_here\x1b[0m"},
+	} {
+		opts := &Options{Width: test.width, WrapNames: test.wrap}
+		if actual := fitLine(opts, test.line, test.indentc, test.props); actual != test.expected {
+			t.Errorf("%q:\ngot:\n%q\nexpected:\n%q", test.line, actual, test.expected)
+		}
+	}
+}
+
+func TestParseTimeFormat(t *testing.T) {
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, test := range []struct {
+		format, expected string
+	}{
+		{"iso", "2021-03-04T05:06:07Z"},
+		{"2006/01/02", "2021/03/04"},
+		{"%b %e %H:%M", "Mar  4 05:06"},
+		{"%F %T 100%%", "2021-03-04 05:06:07 100%"},
+		{"%A %j", "Thursday 063"},
+		{"%Q", ""},
+		{"%", ""},
+		{"", ""},
+	} {
+		format, err := ParseTimeFormat(test.format)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.format, err)
+		} else if actual := formatModTime(&Options{TimeFormat: format}, mtime); actual != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.format, actual, test.expected)
+		}
+	}
+}
+
+func TestASCIIName(t *testing.T) {
+	for _, test := range []struct {
+		name, expected string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"café", "cafe"},
+		{"Straße", "Strasse"},
+		{"“quoted”", `"quoted"`},
+		{"世界.txt", `\u4e16\u754c.txt`},
+		{"😀", `\U0001f600`},
+	} {
+		if actual := asciiName(test.name); actual != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.name, actual, test.expected)
+		}
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, test := range []struct {
+		age      time.Duration
+		expected string
+	}{
+		{10 * time.Second, "10s ago"},
+		{5 * time.Minute, "5m ago"},
+		{3 * time.Hour, "3h ago"},
+		{24 * time.Hour, "1 day ago"},
+		{45 * 24 * time.Hour, "45 days ago"},
+		{90 * 24 * time.Hour, "3 months ago"},
+		{800 * 24 * time.Hour, "2 years ago"},
+		{-2 * time.Hour, "in 2h"},
+	} {
+		actual := relativeTime(now.Add(-test.age), now)
+		if len(actual) != relativeTimeWidth || strings.TrimSpace(actual) != test.expected {
+			t.Errorf("%v: got %q, expected %q", test.age, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package tree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Formatter renders the lines output by Print, so the traversal, sorting and
+// dynamic leveling can be reused with a different output.
+type Formatter interface {
+	// FormatLine returns the line for the node, without a newline. The
+	// indent is the tree structure before the name, name is the (maybe
+	// colorized/quoted/joined) name and props are the enabled property
+	// columns.
+	FormatLine(node *Node, indent, name string, props []string) string
+	// FormatCutoff returns the line output instead of the children of the
+	// node, when dynamic leveling cuts them off. The files is the number of
+	// entries under the node.
+	FormatCutoff(node *Node, indent string, props []string, files int64) string
+}
+
+// TextFormatter is the default Formatter, the properties are in brackets
+// followed by the tree structure and the name.
+type TextFormatter struct{}
+
+// textProps returns the properties prefix for a line
+func textProps(props []string) string {
+	switch len(props) {
+	case 0:
+		return ""
+	case 1:
+		return props[0] + " "
+	}
+	return "[" + strings.Join(props, " ") + "] "
+}
+
+// FormatLine returns the text line for a node.
+func (TextFormatter) FormatLine(node *Node, indent, name string,
+	props []string) string {
+	return fmt.Sprintf("%s%s%s", textProps(props), indent, name)
+}
+
+// FormatCutoff returns the text line for the cut off children of a node,
+// aligned with the name of the node.
+func (TextFormatter) FormatCutoff(node *Node, indent string, props []string,
+	files int64) string {
+	p := message.NewPrinter(language.Make(os.Getenv("LANG")))
+	return p.Sprintf("%*s%s[%d file(s)]", len(textProps(props)), "", indent, files)
+}
+
+// A11yFormatter is a Formatter for screen readers, each line is the depth
+// and the path from the root, like "level 3: src/util/io.go (1.2K)", with
+// no box-drawing. The path is from the end of the node's path, so it's for
+// trees without JoinSingle and FullPath.
+type A11yFormatter struct{}
+
+// a11yProps returns the properties suffix for a line
+func a11yProps(props []string) string {
+	var ret []string
+	for _, prop := range props {
+		if prop = strings.TrimSpace(prop); prop != "" {
+			ret = append(ret, prop)
+		}
+	}
+	if len(ret) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(ret, ", ") + ")"
+}
+
+// FormatLine returns the accessible line for a node.
+func (A11yFormatter) FormatLine(node *Node, indent, name string,
+	props []string) string {
+	if node.depth > 1 {
+		dir := filepath.ToSlash(filepath.Dir(node.relPath()))
+		name = dir + "/" + name
+	}
+	return fmt.Sprintf("level %d: %s%s", node.depth, name, a11yProps(props))
+}
+
+// FormatCutoff returns the accessible line for the cut off children of a
+// node.
+func (A11yFormatter) FormatCutoff(node *Node, indent string, props []string,
+	files int64) string {
+	p := message.NewPrinter(language.Make(os.Getenv("LANG")))
+	return p.Sprintf("level %d: %d more entries not shown", node.depth+1, files)
+}
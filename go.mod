@@ -3,7 +3,10 @@ module github.com/james-antill/tree
 go 1.14
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/ulikunitz/xz v0.5.11
 	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a
+	golang.org/x/sys v0.0.0-20191005200804-aed5e4c7ecf9
 	golang.org/x/text v0.3.0
 )
@@ -0,0 +1,64 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Graphics are the strings drawing the tree, before the names of the
+// entries. Vertical and Space are added to the indent of the children, for
+// the entries after Branch and Last.
+type Graphics struct {
+	Branch   string // Entries with more after them
+	Last     string // The last entry in a dir.
+	Vertical string // Under a Branch
+	Space    string // Under a Last
+	Cutoff   string // The line for the entries not shown
+}
+
+// UTF8Graphics are the box drawing characters, the default.
+var UTF8Graphics = Graphics{
+	Branch:   "┣━ ",
+	Last:     "┗━ ",
+	Vertical: "┃ ",
+	Space:    "  ",
+	Cutoff:   "┖┄ ",
+}
+
+// ASCIIGraphics are for terminals and tools that can't show UTF-8.
+var ASCIIGraphics = Graphics{
+	Branch:   "|-- ",
+	Last:     `\-- `,
+	Vertical: "|   ",
+	Space:    "    ",
+	Cutoff:   `\.. `,
+}
+
+// ParseGraphics returns the Graphics for the charset, ascii or utf-8, or the
+// custom strings "branch,last,vertical,space[,cutoff]".
+func ParseGraphics(charset string) (*Graphics, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8":
+		return &UTF8Graphics, nil
+	case "ascii":
+		return &ASCIIGraphics, nil
+	}
+	parts := strings.Split(charset, ",")
+	if len(parts) != 4 && len(parts) != 5 {
+		return nil, fmt.Errorf("bad charset %q, expected ascii, utf-8 or branch,last,vertical,space[,cutoff]", charset)
+	}
+	g := &Graphics{Branch: parts[0], Last: parts[1], Vertical: parts[2],
+		Space: parts[3], Cutoff: parts[1]}
+	if len(parts) == 5 {
+		g.Cutoff = parts[4]
+	}
+	return g, nil
+}
+
+// graphics returns the Graphics for the options.
+func (opts *Options) graphics() *Graphics {
+	if opts.Graphics == nil {
+		return &UTF8Graphics
+	}
+	return opts.Graphics
+}
@@ -0,0 +1,25 @@
+package tree
+
+import "os"
+
+// seekHole is SEEK_HOLE for lseek(2), the offset of the next hole
+const seekHole = 4
+
+// fileHoles returns if the file at path has holes (it's sparse). ok is false
+// if it can't be checked.
+func fileHoles(path string) (ok, holes bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return false, false
+	}
+	off, err := f.Seek(0, seekHole)
+	if err != nil {
+		return false, false
+	}
+	return true, off < fi.Size()
+}
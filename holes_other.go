@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package tree
+
+// fileHoles returns if the file at path has holes (it's sparse). They are
+// only checked on Linux, elsewhere ok is false.
+func fileHoles(path string) (ok, holes bool) {
+	return false, false
+}
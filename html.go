@@ -0,0 +1,140 @@
+package tree
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// htmlStyle is the CSS for the HTML output, the classes match the
+// colorClass categories so it looks like the ANSI output.
+const htmlStyle = `  body { font-family: monospace; }
+  ul.tree, ul.tree ul { list-style: none; padding-left: 1.5em; }
+  ul.tree details > summary { cursor: pointer; }
+  a { color: inherit; text-decoration: none; }
+  a:hover { text-decoration: underline; }
+  .props { color: #666; }
+  .error { color: #c00; }
+  .exec { color: #080; font-weight: bold; }
+  .archive { color: #c00; font-weight: bold; }
+  .image { color: #a0a; font-weight: bold; }
+  .audio { color: #0aa; font-weight: bold; }
+  .dir { color: #00c; font-weight: bold; }
+  .fifo { color: #aa0; background: #000; }
+  .socket { color: #a0a; background: #000; font-weight: bold; }
+  .device { color: #aa0; background: #000; font-weight: bold; }
+  .orphan { color: #c00; background: #000; font-weight: bold; }
+  .symlink { color: #0aa; font-weight: bold; }
+  .binary { color: #aa0; }
+`
+
+// HTMLHeader writes the start of a standalone HTML page, for the output of
+// Print when Options.HTML is set.
+func HTMLHeader(w io.Writer, title string) {
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+%s</style>
+</head>
+<body>
+`, html.EscapeString(title), htmlStyle)
+}
+
+// HTMLFooter writes the end of the HTML page started with HTMLHeader, the
+// report (if any) is shown under the trees.
+func HTMLFooter(w io.Writer, report string) {
+	if report = strings.TrimSpace(report); report != "" {
+		fmt.Fprintf(w, "<p class=\"report\">%s</p>\n", html.EscapeString(report))
+	}
+	fmt.Fprint(w, "</body>\n</html>\n")
+}
+
+// htmlHREF returns the link for the path rel, relative to the root.
+func htmlHREF(opts *Options, rel string) string {
+	href := (&url.URL{Path: filepath.ToSlash(rel)}).String()
+	if opts.BaseHREF == "" {
+		if href == "" {
+			return "."
+		}
+		return href
+	}
+	if href == "" {
+		return opts.BaseHREF
+	}
+	return strings.TrimSuffix(opts.BaseHREF, "/") + "/" + href
+}
+
+// printHTML prints the node as a nested list item, dirs. are collapsible.
+func (node *Node) printHTML(opts *Options, rel string, layout *Layout) {
+	indent := strings.Repeat("  ", node.depth+1)
+	var name string
+	if node.depth == 0 || opts.FullPath {
+		name = rootName(opts, node)
+	} else {
+		name = node.Name()
+	}
+	if opts.ASCIINames {
+		name = asciiName(name)
+	}
+	if opts.Quotes || (opts.QuoteRoot && node.depth == 0) {
+		name = fmt.Sprintf("\"%s\"", name)
+	}
+
+	if node.err != nil {
+		err := node.err.Error()
+		if msgs := strings.Split(err, ": "); len(msgs) > 1 {
+			err = msgs[1]
+		}
+		fmt.Fprintf(opts.OutFile, "%s<li class=\"error\">%s [%s]</li>\n",
+			indent, html.EscapeString(name), html.EscapeString(err))
+		return
+	}
+
+	var line string
+	if props := node.Props(opts, layout); len(props) == 1 {
+		line = fmt.Sprintf("<span class=\"props\">%s</span> ",
+			html.EscapeString(props[0]))
+	} else if len(props) > 0 {
+		line = fmt.Sprintf("<span class=\"props\">[%s]</span> ",
+			html.EscapeString(strings.Join(props, " ")))
+	}
+	id := "tree-" + (&url.URL{Path: filepath.ToSlash(rel)}).String()
+	line += fmt.Sprintf("<a id=\"%s\" href=\"%s\">%s</a>",
+		html.EscapeString(id), html.EscapeString(htmlHREF(opts, rel)),
+		HTMLColor(node, html.EscapeString(name)))
+	if opts.Classify {
+		line += html.EscapeString(classify(node))
+	}
+	if vtarget := linkTarget(opts, node); vtarget != "" {
+		line += " -&gt; " + html.EscapeString(vtarget)
+	}
+
+	nodes := node.sortedNodes(opts)
+	if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
+		nodes = nil
+	}
+	if !node.IsDir() || len(nodes) == 0 {
+		fmt.Fprintf(opts.OutFile, "%s<li>%s</li>\n", indent, line)
+		return
+	}
+
+	open := ""
+	if node.depth == 0 {
+		open = " open"
+	}
+	fmt.Fprintf(opts.OutFile, "%s<li><details%s><summary>%s</summary>\n",
+		indent, open, line)
+	fmt.Fprintf(opts.OutFile, "%s<ul>\n", indent)
+	for _, nnode := range nodes {
+		nnode.printHTML(opts, path.Join(rel, nnode.Name()), layout)
+	}
+	fmt.Fprintf(opts.OutFile, "%s</ul>\n", indent)
+	fmt.Fprintf(opts.OutFile, "%s</details></li>\n", indent)
+}
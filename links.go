@@ -0,0 +1,72 @@
+package tree
+
+import (
+	"sort"
+	"sync"
+)
+
+// linkKey is the device and inode of a file, the same for all its hard links
+type linkKey struct {
+	dev, ino uint64
+}
+
+// hardLink returns the key for the node, ok is false if it isn't a file with
+// more than one link.
+func hardLink(node *Node) (key linkKey, ok bool) {
+	if node.err != nil || node.IsDir() {
+		return key, false
+	}
+	if ok, nlink := getNlink(node); !ok || nlink < 2 {
+		return key, false
+	}
+	ok, ino, dev, _, _ := getStat(node)
+	return linkKey{dev, ino}, ok
+}
+
+// hiddenLinks are the hard linked files hidden by DirsOnly, dedupLinks adds
+// their sizes to the dir. (the visiting adds the other hidden files).
+type hiddenLinks struct {
+	mu    sync.Mutex
+	nodes Nodes
+}
+
+func (hl *hiddenLinks) add(node *Node) {
+	hl.mu.Lock()
+	hl.nodes = append(hl.nodes, node)
+	hl.mu.Unlock()
+}
+
+// byName returns a copy of the nodes sorted by name, so the first link is
+// the same for every visit.
+func byName(nodes Nodes) Nodes {
+	sorted := append(Nodes(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name() < sorted[j].Name()
+	})
+	return sorted
+}
+
+// dedupLinks marks the hard links after the first one (in name order) as
+// duplicates, so DirRecursiveSize and DiskUsage count each file once like
+// du. See Options.CountLinks.
+func (node *Node) dedupLinks(seen map[linkKey]bool) {
+	if hl := node.hLinks; hl != nil {
+		for _, nnode := range byName(hl.nodes) {
+			key, _ := hardLink(nnode)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			node.hSize += nnode.Size()
+			node.hUsage += DiskUsage(nnode)
+		}
+		node.hLinks = nil
+	}
+	for _, nnode := range byName(node.nodes) {
+		if key, ok := hardLink(nnode); ok {
+			nnode.linkDup = seen[key]
+			seen[key] = true
+		}
+		nnode.dedupLinks(seen)
+	}
+}
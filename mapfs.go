@@ -0,0 +1,120 @@
+package tree
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MapFs is an in-memory Fs (and OpenFs/ReadlinkFs), for tests and synthetic
+// trees. The parent dirs. of each path added are created as needed. The zero
+// value is an empty MapFs.
+type MapFs struct {
+	mu    sync.RWMutex
+	infos map[string]os.FileInfo
+	dirs  map[string][]string
+	links map[string]string
+	data  map[string][]byte
+}
+
+// NewMapFs returns an empty MapFs.
+func NewMapFs() *MapFs {
+	return &MapFs{}
+}
+
+// add the FileInfo for the path, and any parent dirs. that don't exist.
+func (fs *MapFs) add(p string, fi os.FileInfo) {
+	if fs.infos == nil {
+		fs.infos = make(map[string]os.FileInfo)
+		fs.dirs = make(map[string][]string)
+		fs.links = make(map[string]string)
+		fs.data = make(map[string][]byte)
+	}
+	p = filepath.Clean(p)
+	if _, ok := fs.infos[p]; !ok {
+		if dir := filepath.Dir(p); dir != p {
+			if _, ok := fs.infos[dir]; !ok {
+				fs.add(dir, VirtualInfo(filepath.Base(dir), 0,
+					os.ModeDir|0755, fi.ModTime()))
+			}
+			names := append(fs.dirs[dir], filepath.Base(p))
+			sort.Strings(names)
+			fs.dirs[dir] = names
+		}
+	}
+	fs.infos[p] = fi
+}
+
+// AddFile adds a regular file with the data as the content.
+func (fs *MapFs) AddFile(p string, data []byte, perm os.FileMode,
+	mtime time.Time) *MapFs {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.add(p, VirtualInfo(filepath.Base(p), int64(len(data)), perm.Perm(), mtime))
+	fs.data[filepath.Clean(p)] = data
+	return fs
+}
+
+// AddDir adds a dir., it's fine to add a dir. after files in it.
+func (fs *MapFs) AddDir(p string, perm os.FileMode, mtime time.Time) *MapFs {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.add(p, VirtualInfo(filepath.Base(p), 0, os.ModeDir|perm.Perm(), mtime))
+	return fs
+}
+
+// AddSymlink adds a symlink to the target.
+func (fs *MapFs) AddSymlink(p, target string, mtime time.Time) *MapFs {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.add(p, VirtualInfo(filepath.Base(p), int64(len(target)),
+		os.ModeSymlink|0777, mtime))
+	fs.links[filepath.Clean(p)] = target
+	return fs
+}
+
+// Stat returns the FileInfo for the path, symlinks aren't followed.
+func (fs *MapFs) Stat(p string) (os.FileInfo, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fi, ok := fs.infos[filepath.Clean(p)]; ok {
+		return fi, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+}
+
+// ReadDir returns the names of the entries in the dir. path.
+func (fs *MapFs) ReadDir(p string) ([]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	p = filepath.Clean(p)
+	if fi, ok := fs.infos[p]; ok && fi.IsDir() {
+		return append([]string(nil), fs.dirs[p]...), nil
+	}
+	return nil, &os.PathError{Op: "readdir", Path: p, Err: os.ErrNotExist}
+}
+
+// Readlink returns the target of a symlink.
+func (fs *MapFs) Readlink(p string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if target, ok := fs.links[filepath.Clean(p)]; ok {
+		return target, nil
+	}
+	return "", &os.PathError{Op: "readlink", Path: p, Err: os.ErrInvalid}
+}
+
+// Open returns the content of a file.
+func (fs *MapFs) Open(p string) (io.ReadCloser, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if data, ok := fs.data[filepath.Clean(p)]; ok {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+}
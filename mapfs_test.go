@@ -0,0 +1,75 @@
+package tree
+
+import (
+	"testing"
+	"time"
+)
+
+// testTime is the mtime of the MapFs test files
+var testTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// visitMapFs visits the root dir. of the mfs, with the Fs and OutFile of the
+// opts set to the mfs and out.
+func visitMapFs(mfs Fs, opts *Options) *Node {
+	opts.Fs, opts.OutFile = mfs, out
+	inf := New("root")
+	inf.Visit(opts)
+	return inf
+}
+
+// testMapFs visits and prints the root dir. of the mfs with the options of
+// each test (see visitMapFs), and checks the output.
+func testMapFs(t *testing.T, mfs Fs, tests []treeTest) {
+	t.Helper()
+	defer out.clear()
+	for _, test := range tests {
+		out.clear()
+		visitMapFs(mfs, test.opts).Print(test.opts)
+		expected := test.expected[1:]
+		if !out.equal(expected) {
+			t.Errorf("%s:\ngot:\n%+v\nexpected:\n%+v", test.name, out.str, expected)
+		}
+	}
+}
+
+func TestMapFs(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a", []byte("hello\n"), 0644, testTime).
+		AddFile("root/c/e", []byte("ELF\x00"), 0755, testTime).
+		AddDir("root/b", 0755, testTime).
+		AddSymlink("root/c/d", "../a", testTime)
+
+	names, err := mfs.ReadDir("root/c")
+	if err != nil || len(names) != 2 || names[0] != "d" || names[1] != "e" {
+		t.Errorf("readdir: got %v %v", names, err)
+	}
+	if target, err := mfs.Readlink("root/c/d"); err != nil || target != "../a" {
+		t.Errorf("readlink: got %v %v", target, err)
+	}
+	if _, err := mfs.Stat("root/x"); err == nil {
+		t.Errorf("stat: expected an error for a missing path")
+	}
+
+	var zero MapFs // Usable without NewMapFs
+	if _, err := zero.ReadDir("root"); err == nil {
+		t.Errorf("zero: expected an error for a missing dir")
+	}
+	if _, err := zero.AddFile("root/a", nil, 0644, testTime).Stat("root/a"); err != nil {
+		t.Errorf("zero: got %v", err)
+	}
+
+	opts := &Options{Fs: mfs, OutFile: out, ByteSize: true, ShowContent: true}
+	if d, f := New("root").Visit(opts); d != 2 || f != 3 {
+		t.Errorf("visit: got %d dirs %d files", d, f)
+	}
+	testMapFs(t, mfs, []treeTest{
+		{"print", opts, `
+[         14     ] root
+[          6 text] ┣━ a
+[          0     ] ┣━ b
+[          8     ] ┗━ c
+[          4     ]   ┣━ d -> ../a
+[          4 bin ]   ┗━ e
+`, 0, 0},
+	})
+}
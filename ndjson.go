@@ -0,0 +1,48 @@
+package tree
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ndjsonEntry is a single line of the NDJSON output
+type ndjsonEntry struct {
+	Path    string            `json:"path"`
+	Depth   int               `json:"depth"`
+	Size    int64             `json:"size"`
+	Mode    string            `json:"mode"`
+	ModTime time.Time         `json:"mtime"`
+	Sum     string            `json:"checksum,omitempty"`
+	Err     string            `json:"error,omitempty"`
+	Extra   map[string]string `json:"extra,omitempty"`
+}
+
+// emitNDJSON writes the node as a line of JSON, as soon as it's been stat'd,
+// so nothing needs to be kept for huge trees.
+func (node *Node) emitNDJSON(opts *Options) {
+	if opts.DeepLevel > 0 && node.depth > opts.DeepLevel {
+		return
+	}
+
+	ent := ndjsonEntry{
+		Path:    node.path,
+		Depth:   node.depth,
+		Size:    node.Size(),
+		Mode:    node.Mode().String(),
+		ModTime: node.ModTime(),
+		Sum:     node.csum,
+		Extra:   node.extra,
+	}
+	if node.err != nil {
+		ent.Err = node.err.Error()
+	}
+	data, err := json.Marshal(ent)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	opts.outMu.Lock()
+	defer opts.outMu.Unlock()
+	opts.OutFile.Write(data)
+}
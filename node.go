@@ -1,20 +1,20 @@
 package tree
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/sync/semaphore"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 	"io"
 	"os"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
@@ -22,13 +22,36 @@ import (
 // contains FileInfo, and its childs
 type Node struct {
 	os.FileInfo
-	path   string
-	depth  int
-	dSize  int64
-	err    error
-	nodes  Nodes
-	sorted bool
-	vpaths map[string]bool
+	path    string
+	depth   int
+	dSize   int64
+	dUsage  int64     // Cache for DiskUsage
+	dCount  int64     // Cache for countEntries
+	hSize   int64     // The size of the files hidden by DirsOnly
+	hFiles  int64     // The number of files hidden by DirsOnly
+	hUsage  int64     // The DiskUsage of the files hidden by DirsOnly
+	newest  time.Time // Cache for NewestModTime
+	btime   time.Time // The birth time, for BirthTime or BTimeSort
+	err     error
+	nodes   Nodes
+	sorted  bool
+	virtual bool
+	ctype   contentType
+	csum    string // The Options.Checksum of the file
+	extra   map[string]string
+	vpaths  *pathSet
+	vs      *visitState
+	ctx     context.Context
+	ignore  *ignoreRules // The .treeignore rules for the entries
+	shallow bool         // Don't read the dir. when visiting, for Stream
+	hLinks  *hiddenLinks // The hard links hidden by DirsOnly, see dedupLinks
+	linkDup bool         // A hard link to a file already in the sizes
+	index   int          // The ReadDir order of the entry, for NoSort
+	xattrs  []string     // The extended attribute names, see checkXattrs
+	policy  string       // The Options.Policy violations, see checkPolicy
+	partial bool         // Not finished when the ctx was done, see markIncomplete
+	saving  string       // Why the file uses less disk, for Options.Savings
+	sparse  bool         // A sparse file, for Options.Sparse
 }
 
 // List of nodes
@@ -42,6 +65,82 @@ type Fs interface {
 	ReadDir(path string) ([]string, error)
 }
 
+// ReadlinkFs is an optional interface for a Fs, to get the target of
+// symlinks. Otherwise os.Readlink is used.
+type ReadlinkFs interface {
+	Readlink(path string) (string, error)
+}
+
+// DirFs is an optional interface for a Fs, to read the names in a dir. in
+// chunks so huge dirs. (like maildirs) are never all in memory. An *os.File
+// is a DirReader.
+type DirFs interface {
+	OpenDir(path string) (DirReader, error)
+}
+
+// DirReader reads the names in a dir., like os.File.Readdirnames(n) with
+// n > 0 it returns io.EOF at the end. Fewer than n names is also the end, so
+// the dir. isn't kept open while visiting the entries of small dirs.
+type DirReader interface {
+	Readdirnames(n int) ([]string, error)
+	Close() error
+}
+
+// readDirChunk is how many names are read at once from a DirFs
+const readDirChunk = 4096
+
+// dirNames are the names in a dir., read in chunks for a DirFs or all at
+// once otherwise.
+type dirNames struct {
+	dir   DirReader
+	names []string
+}
+
+func openDirNames(opts *Options, path string) (*dirNames, error) {
+	if dfs, ok := opts.Fs.(DirFs); ok {
+		dir, err := dfs.OpenDir(path)
+		if err != nil {
+			return nil, err
+		}
+		return &dirNames{dir: dir}, nil
+	}
+	names, err := opts.Fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return &dirNames{names: names}, nil
+}
+
+// next returns the next chunk of names, or none at the end.
+func (d *dirNames) next() ([]string, error) {
+	if d.dir == nil {
+		names := d.names
+		d.names = nil
+		return names, nil
+	}
+	names, err := d.dir.Readdirnames(readDirChunk)
+	if err == io.EOF || (err == nil && len(names) < readDirChunk) {
+		d.close()
+		err = nil
+	}
+	return names, err
+}
+
+func (d *dirNames) close() {
+	if d.dir != nil {
+		d.dir.Close()
+		d.dir = nil
+	}
+}
+
+// readlink returns the target of the symlink, using the Fs if possible.
+func readlink(opts *Options, path string) (string, error) {
+	if rfs, ok := opts.Fs.(ReadlinkFs); ok {
+		return rfs.Readlink(path)
+	}
+	return os.Readlink(path)
+}
+
 // Options store the configuration for specific tree.
 // Note, that 'Fs', and 'OutFile' are required (OutFile can be os.Stdout).
 type Options struct {
@@ -50,40 +149,248 @@ type Options struct {
 	// List
 	All        bool
 	DirsOnly   bool
+	LinksOnly  bool
+	Content    string
 	FullPath   bool
 	IgnoreCase bool
 	FollowLink bool
 	DeepLevel  int
 	Pattern    string
 	IPattern   string
+	// Glob makes Pattern and IPattern globs separated by |, like *.go|*.c,
+	// instead of regexps. Globs with a / match the path from the root, and
+	// ** matches any number of dirs.
+	Glob bool
+	// SkipSuffixes hides the entries with names ending in them, nil is
+	// DefaultSkipSuffixes and empty shows everything.
+	SkipSuffixes []string
+	// Types shows only the files of the types, if it's set. Dirs. are
+	// still shown for the tree structure.
+	Types EntryType
+	// User and Group show only the files owned by the user/group, as a
+	// name or id. Dirs. are still shown for the tree structure.
+	User  string
+	Group string
+	// Where is a filter expression for files, see CompileWhere.
+	Where *Where
+	// Newer and Older show only the files modified after/before the times,
+	// if they're set (see ParseTime).
+	Newer time.Time
+	Older time.Time
+	// Sample reads only this fraction of the dirs. under each dir., so the
+	// tree is a sample and the report has the estimated totals (see
+	// EstimateTree). 0 reads everything.
+	Sample float64
+	// IgnoreErrors are paths where errors aren't shown, entries that can't
+	// be stat'd are dropped and dirs. that can't be read look empty.
+	IgnoreErrors []string
+	// Errors is where the errors are printed, instead of in the tree.
+	Errors io.Writer
+	// ExcludePseudo skips dirs. on kernel generated filesystems, like /proc.
+	ExcludePseudo bool
+	// TreeIgnore skips entries matching the gitignore style patterns in
+	// the .treeignore files of each dir., needs an OpenFs. And in the
+	// TreeIgnoreFile from the OS (like ~/.treeignore), for every root.
+	TreeIgnore     bool
+	TreeIgnoreFile string
+	// GitIgnore skips entries matching the .gitignore files, like
+	// TreeIgnore, and the GitExcludes file (core.excludesFile) from the OS.
+	GitIgnore   bool
+	GitExcludes string
 	// File
 	ByteSize bool
 	UnitSize bool
 	FileMode bool
-	ShowUid  bool
-	ShowGid  bool
-	LastMod  bool
-	Quotes   bool
-	Inodes   bool
-	Device   bool
+	// OctalMode shows the permissions like 0644, before any FileMode.
+	OctalMode bool
+	ShowUid   bool
+	ShowGid   bool
+	LastMod   bool
+	Quotes    bool
+	Inodes    bool
+	Device    bool
+	// LinkCount shows the number of hard links (st_nlink), after Device.
+	LinkCount bool
+	// Xattrs marks the entries with extended attributes with a @ after the
+	// mode, like BSD ls, and XattrNames lists them after the name.
+	Xattrs     bool
+	XattrNames bool
+	// Context shows the SELinux security context, after the Uid/Gid like
+	// ls -Z. It's only read on Linux.
+	Context bool
+	// LinkTargets shows the mode, size and date of the symlink targets,
+	// after them. The broken links are marked without it.
+	LinkTargets bool
+	// LocaleTime formats the LastMod dates for the locale (LC_TIME or
+	// LANG), instead of ISO.
+	LocaleTime bool
+	// TimeFormat is the Go layout, or strftime(3) format if it has a %,
+	// for the LastMod dates, over LocaleTime. See ParseTimeFormat.
+	TimeFormat string
+	// RelativeTime shows the LastMod dates as ages, like "3h ago", over
+	// TimeFormat.
+	RelativeTime bool
+	// BirthTime shows the creation dates after the LastMod dates, in the
+	// same format. It's blank if the OS or filesystem doesn't store it.
+	BirthTime bool
+	// AccessTime shows the last access dates after them, in the same format.
+	AccessTime bool
+	// MtimeRollup uses the newest mtime under each dir., for LastMod and
+	// ModSort.
+	MtimeRollup bool
+	// Allocated shows the size allocated on disk after the size, like
+	// 1.2G/1.4G, so sparse and compressed files stand out.
+	Allocated bool
+	// CountLinks counts the size of hard linked files for every link, like
+	// du -l, instead of for the first one (in name order).
+	CountLinks bool
+	// Savings flags the files using less than half their size on disk
+	// (sparse or compressed), and the report has the total saved.
+	Savings bool
+	// Sparse flags the files using less than half their size on disk that
+	// have holes, or might (when that can't be checked).
+	Sparse bool
+	// HideEmptySize shows the size of dirs. with no content as blank, or
+	// as EmptySizeText if that's set.
+	HideEmptySize bool
+	EmptySizeText string
+	// ShowContent shows if files are text or binary
+	ShowContent bool
+	// Checksum shows the hex digest of the files, with the hash: sha256, md5
+	// or xxh64 in any case (see ParseChecksum). Other hashes aren't shown,
+	// and Run returns an error for them.
+	Checksum string
+	// Files bigger than ContentMaxSize (if > 0), or with names matching a
+	// NoContent glob, never have their content read.
+	ContentMaxSize int64
+	NoContent      []string
 	// Sort
 	NoSort    bool
 	VerSort   bool
 	ModSort   bool
 	DirSort   bool
+	DirLast   bool
 	NameSort  bool
 	SizeSort  bool
 	CTimeSort bool
+	BTimeSort bool
+	ATimeSort bool
+	CountSort bool
 	ReverSort bool
+	// SortBy sorts the entries with the func, over the other sorts. The
+	// entries it finds equal stay in name order, and DirSort and ReverSort
+	// still apply. See SortByKey.
+	SortBy SortFunc
 	// Graphics
 	NoIndent   bool
 	Colorize   bool
 	JoinSingle bool
 	Classify   bool
 	NumericIDs bool
+	// QuoteRoot quotes the root paths, like Quotes does for all names.
+	// DotRoot shows the root as "." when it's the current dir.
+	QuoteRoot bool
+	DotRoot   bool
+	// Graphics draw the tree, the default is UTF8Graphics.
+	Graphics *Graphics
+	// DirColors are the styles for Colorize, instead of the defaults.
+	DirColors *DirColors
+	// Width is the columns for the text lines, longer lines are truncated
+	// with an ellipsis or with WrapNames wrapped under the name. 0 is no
+	// limit.
+	Width     int
+	WrapNames bool
+	// ASCIINames transliterates or escapes the non-ASCII in the names, for
+	// the consoles that can't show them. The structured outputs (JSON, XML,
+	// NDJSON, CSV) keep the names as they are.
+	ASCIINames bool
+	// AgeBuckets colors the entries by the age of their mtime (with
+	// Colorize), and adds the files in each bucket to the report.
+	AgeBuckets *AgeBuckets
+	// Hyperlink makes the names OSC 8 links to their file:// URLs, for the
+	// terminals that support them.
+	Hyperlink bool
+	// JoinCounts shows the number of files and the size of the dirs. that
+	// JoinSingle joins into one line.
+	JoinCounts bool
+	// PathSep is the separator shown in joined names and full paths, the
+	// default is the native one (filepath.Separator).
+	PathSep   string
+	Formatter Formatter
+	// Template is executed for each node (with a TemplateNode), instead of
+	// the Formatter.
+	Template *template.Template
+	HTML     bool
+	BaseHREF string
+	NDJSON   bool
+	CSV      bool
+	TSV      bool
+	JSON     bool
+	XML      bool
+	Markdown bool
+	NoReport bool
+	// Compat is the tree command the text output is like, see SetCompat.
+	Compat string
+	// UsageReport prints the entries (inodes) and size of each top-level
+	// dir. after the report, sorted by: entries, size or name.
+	UsageReport string
+	// DepthReport adds the max depth and the longest path to the report,
+	// for the tools with path length limits.
+	DepthReport bool
+	// Stats prints the statistics of the trees after the report, the size
+	// histogram and the StatsTop (default 10) largest files, dirs. and
+	// extensions. StatsOnly doesn't print the trees. They are only for the
+	// text output, and not with Stream (Run returns an error).
+	Stats     bool
+	StatsTop  int
+	StatsOnly bool
+	// Stream prints the text output while visiting, see Node.Stream.
+	Stream bool
+	// Encoding is what Run converts the output to, see EncodeOutput.
+	Encoding string
+	// Policy flags the entries that don't have the expected permissions.
+	Policy *PermPolicy
+	// Concurrency is the max. number of goroutines visiting dirs., the
+	// default is 32 and 1 visits everything serially.
+	Concurrency int
+	// Pool is shared with other Options, for the goroutines visiting dirs.
+	// it's used instead of Concurrency.
+	Pool *Pool
+	// Throttle is the max. number of stat/readdir calls per second, 0 is no
+	// limit. Goroutines wait for their turn, so it's the total for the tree.
+	Throttle int
+	// Hooks
+	VisitWrapper func(next VisitFn) VisitFn
+	// Inject returns virtual nodes (see NewVirtual) to add to the dir.,
+	// the real children of the dir. may still be being visited.
+	Inject func(dir *Node) Nodes
+	// Progress is called from Visit, at most every 100ms, with the number
+	// of dirs. and files visited so far and the path being visited.
+	Progress func(visitedDirs, visitedFiles int64, currentPath string)
+	// StreamTotals is called by Stream after each entry is printed, with
+	// the running totals for the report (over all the roots for Run).
+	StreamTotals func(node *Node, totals Summary)
+
+	visitOnce sync.Once
+	visitFn   VisitFn
+
+	semOnce sync.Once
+	sem     *semaphore.Weighted
+
+	throttleOnce sync.Once
+	throttle     *throttle
+
+	outMu   sync.Mutex
+	printed int // Number of roots printed, for the JSON separators
+
+	progOnce sync.Once
+	prog     *progressState
+}
 
+// visitState is shared by all the nodes of a single Visit() from a root, so
+// multiple roots can be visited at once with the same Options.
+type visitState struct {
 	wg  sync.WaitGroup
-	sem *semaphore.Weighted
 	res chan workerResult
 }
 
@@ -97,39 +404,62 @@ type workerResult struct {
 
 // New get path and create new node(root).
 func New(path string) *Node {
-	return &Node{path: path, vpaths: make(map[string]bool)}
+	return &Node{path: path, vpaths: newPathSet()}
+}
+
+// pathSet is the set of visited paths, when following symlinks. It's shared
+// by the goroutines visiting a tree.
+type pathSet struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func newPathSet() *pathSet {
+	return &pathSet{paths: make(map[string]bool)}
+}
+
+// add the path to the set.
+func (ps *pathSet) add(path string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.paths[path] = true
+}
+
+// has returns if the path is in the set.
+func (ps *pathSet) has(path string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.paths[path]
 }
 
 func newSubNode(opts *Options, node *Node, name string) (nnode *Node, dirs, files int) {
 	nnode = &Node{
-		path:   filepath.Join(node.path, name),
-		depth:  node.depth + 1,
-		vpaths: node.vpaths,
-	}
-	d, f := nnode.Visit(opts)
-	if nnode.err == nil && !nnode.IsDir() {
-		// "dirs only" option
-		if opts.DirsOnly {
-			return nil, 0, 0
-		}
-		var rePrefix string
-		if opts.IgnoreCase {
-			rePrefix = "(?i)"
-		}
-		// Pattern matching
-		if opts.Pattern != "" {
-			re, err := regexp.Compile(rePrefix + opts.Pattern)
-			if err == nil && !re.MatchString(name) {
-				return nil, 0, 0
-			}
-		}
-		// IPattern matching
-		if opts.IPattern != "" {
-			re, err := regexp.Compile(rePrefix + opts.IPattern)
-			if err == nil && re.MatchString(name) {
-				return nil, 0, 0
+		path:    filepath.Join(node.path, name),
+		depth:   node.depth + 1,
+		vpaths:  node.vpaths,
+		vs:      node.vs,
+		ctx:     node.ctx,
+		ignore:  node.ignore,
+		shallow: node.shallow,
+	}
+	d, f, err := visitNode(opts, nnode)
+	if err == SkipNode {
+		return nil, 0, 0
+	}
+	if nnode.err == nil && !nnode.IsDir() && skipFile(opts, nnode) != "" {
+		if opts.DirsOnly { // The size is still in the dir.
+			atomic.AddInt64(&node.hFiles, 1)
+			if _, ok := hardLink(nnode); ok && node.hLinks != nil {
+				node.hLinks.add(nnode) // Added by dedupLinks
+			} else {
+				atomic.AddInt64(&node.hSize, nnode.Size())
+				atomic.AddInt64(&node.hUsage, DiskUsage(nnode))
 			}
 		}
+		return nil, 0, 0
+	}
+	if nnode.err != nil && ignoreError(opts, nnode.path) {
+		return nil, 0, 0
 	}
 
 	return nnode, d, f
@@ -159,29 +489,139 @@ func (n errFI) Sys() interface{} {
 }
 
 const semWeight = 64
+
+// semWeight returns the weight of the semaphore for the visiting goroutines,
+// each one uses 2.
+func (opts *Options) semWeight() int64 {
+	if opts.Pool != nil {
+		return opts.Pool.weight
+	}
+	if opts.Concurrency > 0 {
+		return 2 * int64(opts.Concurrency)
+	}
+	return semWeight
+}
+
 const rootProc = true
 
+// VisitFn visits a single node, filling in its FileInfo and children, and
+// returns the number of dirs. and files found. Returning SkipNode drops the
+// node from its parent, any other error is stored on the node.
+type VisitFn func(opts *Options, node *Node) (dirs, files int, err error)
+
+// SkipNode is returned by a VisitFn to not include the node in the tree, or
+// by a Walk func to not walk the children of the node.
+var SkipNode = errors.New("skip this node")
+
+// visitNode visits the node through the Options.VisitWrapper, if any. A
+// panic, like from a broken Fs, is the error for the node instead of
+// crashing the worker goroutine (and the process).
+func visitNode(opts *Options, node *Node) (dirs, files int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if node.FileInfo == nil {
+				node.FileInfo = errFI(filepath.Base(node.path))
+			}
+			node.err = &os.PathError{Op: "panic", Path: node.path, Err: fmt.Errorf("%v", r)}
+			dirs, files, err = 0, 0, nil
+		}
+	}()
+	opts.visitOnce.Do(func() {
+		opts.visitFn = defaultVisit
+		if opts.VisitWrapper != nil {
+			opts.visitFn = opts.VisitWrapper(defaultVisit)
+		}
+	})
+	dirs, files, err = opts.visitFn(opts, node)
+	if node.FileInfo == nil { // So this isn't nil, even when skipped
+		node.FileInfo = errFI(filepath.Base(node.path))
+	}
+	if err != nil && err != SkipNode && node.err == nil {
+		node.err = err
+	}
+	return
+}
+
+// defaultVisit is the VisitFn that does the real work.
+func defaultVisit(opts *Options, node *Node) (dirs, files int, err error) {
+	return node.visit(opts)
+}
+
 // Visit all files under the given node.
 func (node *Node) Visit(opts *Options) (dirs, files int) {
-	goProcs := !opts.FollowLink && (semWeight > 0)
+	return node.VisitContext(context.Background(), opts)
+}
 
+// VisitContext visits all files under the given node, until the ctx is done.
+// Then no more dirs. are read, and the dirs. not finished have the ctx error.
+// Note that Fs calls already started still have to return.
+func (node *Node) VisitContext(ctx context.Context,
+	opts *Options) (dirs, files int) {
+	node.ctx = ctx
+	dirs, files, _ = visitNode(opts, node)
+	if !opts.CountLinks {
+		node.dedupLinks(make(map[linkKey]bool))
+	}
+	return
+}
+
+// context returns the context the node is being visited with.
+func (node *Node) context() context.Context {
+	if node.ctx == nil {
+		return context.Background()
+	}
+	return node.ctx
+}
+
+// visit all files under the given node, children are visited with visitNode.
+func (node *Node) visit(opts *Options) (dirs, files int, err error) {
 	// visited paths
 	if !opts.FollowLink {
 		node.vpaths = nil
 	} else if path, err := filepath.Abs(node.path); err == nil {
-		path = filepath.Clean(path)
-		node.vpaths[path] = true
+		if node.vpaths == nil {
+			node.vpaths = newPathSet()
+		}
+		node.vpaths.add(filepath.Clean(path))
 	}
 	// stat
+	if err := opts.waitFs(node.context()); err != nil {
+		node.err = err
+		node.FileInfo = errFI(filepath.Base(node.path)) // So this isn't nil
+		return 0, 0, nil
+	}
 	fi, err := opts.Fs.Stat(node.path)
 	if err != nil {
 		node.err = err
 		node.FileInfo = errFI(filepath.Base(node.path)) // So this isn't nil
-		return
+		if opts.NDJSON && !ignoreError(opts, node.path) {
+			node.emitNDJSON(opts)
+		}
+		return 0, 0, nil
 	}
 	node.FileInfo = fi
+	if fi.IsDir() && node.depth != 0 && skipDir(opts, node) != "" {
+		return 0, 0, SkipNode
+	}
+	if node.depth != 0 && skipIgnored(opts, node) != "" {
+		return 0, 0, SkipNode
+	}
+	if opts.Progress != nil {
+		node.progress(opts)
+	}
+	node.checkContent(opts)
+	node.checkChecksum(opts)
+	node.checkXattrs(opts)
+	if opts.BirthTime || opts.BTimeSort {
+		node.btime, _ = getBirthTime(node)
+	}
+	node.checkPolicy(opts)
+	node.checkSavings(opts)
+	if opts.NDJSON && (fi.IsDir() || skipFile(opts, node) == "") {
+		node.emitNDJSON(opts)
+	}
 	if !fi.IsDir() {
-		return 0, 1
+		return 0, 1, nil
 	}
 	// increase dirs only if it's a dir, but not the root.
 	if node.depth != 0 {
@@ -192,17 +632,50 @@ func (node *Node) Visit(opts *Options) (dirs, files int) {
 	if !showSize && (opts.DeepLevel > 0 && opts.DeepLevel <= node.depth) {
 		return
 	}
-	names, err := opts.Fs.ReadDir(node.path)
-	if err != nil {
+	if node.shallow { // Streaming, the dir. is read when it's printed
+		return
+	}
+	d, f := node.visitDir(opts)
+	return dirs + d, f, nil
+}
+
+// visitDir reads the entries of the dir. node, and visits them.
+func (node *Node) visitDir(opts *Options) (dirs, files int) {
+	goProcs := (opts.Concurrency != 1 || opts.Pool != nil) && (semWeight > 0)
+	ctx := node.context()
+	if err := opts.waitFs(ctx); err != nil {
 		node.err = err
 		return
 	}
+	dnames, err := openDirNames(opts, node.path)
+	if err != nil {
+		if !ignoreError(opts, node.path) {
+			node.err = err
+		}
+		return
+	}
+	defer dnames.close()
+	if opts.TreeIgnore || opts.GitIgnore {
+		node.ignore = loadIgnores(opts, node.path, node.ignore, node.depth == 0)
+	}
 	node.nodes = make(Nodes, 0)
+	if opts.DirsOnly && !opts.CountLinks {
+		node.hLinks = &hiddenLinks{}
+	}
+	idx := 0 // The ReadDir order, the goroutines finish in any order
 	var rwg sync.WaitGroup
 	var fin chan workerResult
-	if goProcs && node.depth == 0 {
-		opts.sem = semaphore.NewWeighted(semWeight)
-		opts.res = make(chan workerResult, semWeight)
+	if goProcs && node.vs == nil {
+		// The semaphore is shared by all the roots using these options, or
+		// all the Options using the Pool.
+		opts.semOnce.Do(func() {
+			if opts.Pool != nil {
+				opts.sem = opts.Pool.sem
+			} else {
+				opts.sem = semaphore.NewWeighted(opts.semWeight())
+			}
+		})
+		node.vs = &visitState{res: make(chan workerResult, opts.semWeight())}
 		rwg.Add(1)
 		fin = make(chan workerResult)
 		go func() {
@@ -210,61 +683,99 @@ func (node *Node) Visit(opts *Options) (dirs, files int) {
 			defer close(fin)
 			mdirs := 0
 			mfiles := 0
-			for val := range opts.res {
-				val.p.nodes = append(val.p.nodes, val.n)
+			for val := range node.vs.res {
+				if !opts.NDJSON { // Streamed, so don't keep the nodes
+					val.p.nodes = append(val.p.nodes, val.n)
+				}
 				mdirs, mfiles = mdirs+val.d, mfiles+val.f
 			}
 			fin <- workerResult{nil, node, mdirs, mfiles}
 		}()
+		// Deferred, so the goroutines are finished even after a panic
+		defer func() {
+			node.vs.wg.Wait()
+			close(node.vs.res)
+			val := <-fin
+			dirs += val.d
+			files += val.f
+			rwg.Wait()
+		}()
 	}
-	for i := range names {
-		name := names[i]
-		// "all" option
-		if !opts.All && strings.HasPrefix(name, ".") {
-			continue
+chunks:
+	for {
+		if dnames.dir != nil { // Each chunk is a call to the DirFs
+			if err := opts.waitFs(ctx); err != nil {
+				node.err = err
+				break
+			}
 		}
-		if strings.HasSuffix(name, "~") {
-			continue
+		names, err := dnames.next()
+		if err != nil {
+			if !ignoreError(opts, node.path) {
+				node.err = err
+			}
+			break
 		}
-		if strings.HasSuffix(name, ".bak") {
-			continue
+		if len(names) == 0 {
+			break
 		}
-		if strings.HasSuffix(name, ".swp") && false {
-			continue
-		}
-		if goProcs && (rootProc || node.depth != 0) {
-			if opts.sem.TryAcquire(2) {
-				opts.wg.Add(1)
-				go func() {
-					defer opts.wg.Done()
-					defer opts.sem.Release(2)
-					nnode, d, f := newSubNode(opts, node, name)
-					if nnode == nil {
-						return
-					}
-					opts.res <- workerResult{node, nnode, d, f}
-				}()
+		for i := range names {
+			name := names[i]
+			if skipName(opts, name) != "" {
 				continue
 			}
+			if err := ctx.Err(); err != nil {
+				node.err = err
+				break chunks
+			}
+			index := idx
+			idx++
+			if goProcs && (rootProc || node.depth != 0) {
+				if opts.sem.TryAcquire(2) {
+					node.vs.wg.Add(1)
+					go func() {
+						defer node.vs.wg.Done()
+						defer opts.sem.Release(2)
+						nnode, d, f := newSubNode(opts, node, name)
+						if nnode == nil {
+							return
+						}
+						nnode.index = index
+						node.vs.res <- workerResult{node, nnode, d, f}
+					}()
+					continue
+				}
+			}
+			nnode, d, f := newSubNode(opts, node, name)
+			if nnode == nil {
+				continue
+			}
+			nnode.index = index
+			if goProcs && (rootProc || node.depth != 0) {
+				node.vs.res <- workerResult{node, nnode, d, f}
+				continue
+			}
+			if !opts.NDJSON { // Streamed, so don't keep the nodes
+				node.nodes = append(node.nodes, nnode)
+			}
+			dirs, files = dirs+d, files+f
 		}
-		nnode, d, f := newSubNode(opts, node, name)
-		if nnode == nil {
-			continue
-		}
-		if goProcs && (rootProc || node.depth != 0) {
-			opts.res <- workerResult{node, nnode, d, f}
-			continue
-		}
-		node.nodes = append(node.nodes, nnode)
-		dirs, files = dirs+d, files+f
 	}
-	if goProcs && node.depth == 0 {
-		opts.wg.Wait()
-		close(opts.res)
-		val := <-fin
-		dirs += val.d
-		files += val.f
-		rwg.Wait()
+	// Virtual entries
+	if opts.Inject != nil {
+		for _, vnode := range opts.Inject(node) {
+			d, f := vnode.setupVirtual(opts, node)
+			vnode.index = idx
+			idx++
+			if goProcs && (rootProc || node.depth != 0) {
+				node.vs.res <- workerResult{node, vnode, d, f}
+				continue
+			}
+			if !opts.NDJSON { // Streamed, so don't keep the nodes
+				node.nodes = append(node.nodes, vnode)
+			}
+			dirs, files = dirs+d, files+f
+		}
 	}
 	return
 }
@@ -283,11 +794,22 @@ func (node *Node) sort(opts *Options) {
 	var nSort bool
 	switch {
 	case opts.NoSort:
+		sort.Sort(ByFunc{node.nodes, readDirSort})
 		return
+	case opts.SortBy != nil:
+		fn = opts.SortBy
+	case opts.ModSort && opts.MtimeRollup:
+		fn = NewestModSort
 	case opts.ModSort:
 		fn = ModSort
 	case opts.CTimeSort:
 		fn = CTimeSort
+	case opts.BTimeSort:
+		fn = BTimeSort
+	case opts.ATimeSort:
+		fn = ATimeSort
+	case opts.CountSort:
+		fn = CountSort
 	case opts.VerSort:
 		fn = VerSort
 		nSort = true
@@ -309,6 +831,11 @@ func (node *Node) sort(opts *Options) {
 		fn = func(f1, f2 *Node) bool {
 			return DirSort(f1, f2, nxt)
 		}
+	} else if opts.DirLast {
+		nxt := fn
+		fn = func(f1, f2 *Node) bool {
+			return DirLastSort(f1, f2, nxt)
+		}
 	}
 	if fn != nil {
 		if opts.ReverSort {
@@ -320,7 +847,36 @@ func (node *Node) sort(opts *Options) {
 }
 
 // Print nodes based on the given configuration.
-func (node *Node) Print(opts *Options) { node.print(opts, "", "", 0, nil) }
+func (node *Node) Print(opts *Options) {
+	if opts.NDJSON { // Already output by Visit
+		return
+	}
+	if opts.CSV || opts.TSV {
+		cw := newCSVWriter(opts, opts.OutFile)
+		node.printCSV(cw, opts)
+		cw.Flush()
+		return
+	}
+	defer func() { opts.printed++ }()
+	switch {
+	case opts.JSON:
+		node.printJSON(opts)
+	case opts.XML:
+		node.printXML(opts)
+	case opts.Markdown:
+		layout := &Layout{}
+		layout.addLevels(opts, node)
+		node.printMarkdown(opts, layout)
+	case opts.HTML:
+		layout := &Layout{}
+		layout.addLevels(opts, node)
+		fmt.Fprintln(opts.OutFile, "<ul class=\"tree\">")
+		node.printHTML(opts, "", layout)
+		fmt.Fprintln(opts.OutFile, "</ul>")
+	default:
+		node.print(opts, "", "", 0, nil)
+	}
+}
 
 // dirDirectChildren give the direct dirs. and files for a directory
 func dirDirectChildren(node *Node) (int64, int64) {
@@ -414,19 +970,58 @@ func dirRecursiveChildren(opts *Options, node *Node) (num int64, err error) {
 	return num, err
 }
 
+// dirRecursiveFiles returns the number of files under the dir.
+func dirRecursiveFiles(node *Node) (num int64) {
+	for _, nnode := range node.nodes {
+		if nnode.err != nil {
+			continue
+		}
+		if !nnode.IsDir() {
+			num++
+			continue
+		}
+		num += dirRecursiveFiles(nnode)
+	}
+	return num
+}
+
+// NewestModTime returns the newest mtime of the node, or anything under it.
+func NewestModTime(node *Node) time.Time {
+	if !node.newest.IsZero() {
+		return node.newest
+	}
+
+	newest := node.ModTime()
+	for _, nnode := range node.nodes {
+		if nnode.err != nil {
+			continue
+		}
+		if mtime := NewestModTime(nnode); mtime.After(newest) {
+			newest = mtime
+		}
+	}
+	node.newest = newest
+	return newest
+}
+
 // DirRecursiveSize returns the size of the directory, as the total of all
-// child nodes.
+// child nodes and the files hidden by DirsOnly.
 func DirRecursiveSize(node *Node) (size int64, err error) {
 	if node.dSize > 0 {
 		return node.dSize, nil
 	}
 
+	size = node.hSize
+
 	for _, nnode := range node.nodes {
 		if nnode.err != nil {
 			err = nnode.err
 			continue
 		}
 
+		if nnode.linkDup {
+			continue
+		}
 		if !nnode.IsDir() {
 			size += nnode.Size()
 		} else {
@@ -444,6 +1039,28 @@ func DirRecursiveSize(node *Node) (size int64, err error) {
 	return
 }
 
+// DiskUsage returns the bytes allocated on disk for the file, or the files
+// under the dir. (like DirRecursiveSize). When it isn't known, it's the size.
+func DiskUsage(node *Node) int64 {
+	if !node.IsDir() {
+		if ok, size := getAllocated(node); ok {
+			return size
+		}
+		return node.Size()
+	}
+	if node.dUsage > 0 {
+		return node.dUsage
+	}
+	size := node.hUsage
+	for _, nnode := range node.nodes {
+		if nnode.err == nil && !nnode.linkDup {
+			size += DiskUsage(nnode)
+		}
+	}
+	node.dUsage = size
+	return size
+}
+
 // NodeSize returns the size of the directory/file, errors are ignored.
 func NodeSize(node *Node) int64 {
 	if !node.IsDir() {
@@ -502,7 +1119,19 @@ func joinSingleNodes(opts *Options, node *Node, name string) (*Node, string) {
 	if opts.Device {
 		return node, name
 	}
-	if opts.FileMode {
+	if opts.LinkCount {
+		return node, name
+	}
+	if opts.FileMode || opts.OctalMode {
+		return node, name
+	}
+	if opts.Xattrs || opts.XattrNames {
+		return node, name
+	}
+	if opts.Context {
+		return node, name
+	}
+	if opts.Checksum != "" { // The joined file's checksum isn't shown
 		return node, name
 	}
 	if opts.ShowUid {
@@ -511,29 +1140,80 @@ func joinSingleNodes(opts *Options, node *Node, name string) (*Node, string) {
 	if opts.ShowGid {
 		return node, name
 	}
-	if opts.LastMod {
+	if opts.LastMod || opts.BirthTime || opts.AccessTime {
+		return node, name
+	}
+	// Showing size is fine, because it's just an empty dir. Unless it has
+	// files hidden by DirsOnly, which are in the size.
+	if node.hFiles > 0 && (opts.ByteSize || opts.UnitSize) {
 		return node, name
 	}
-	// Showing size is fine, because it's just an empty dir.
 	if opts.FullPath {
 		return node, name
 	}
 	nxt := node.nodes[0]
 
 	nxtName := nxt.Name()
+	if opts.ASCIINames {
+		nxtName = asciiName(nxtName)
+	}
 	// Quotes
 	if opts.Quotes {
 		nxtName = fmt.Sprintf("\"%s\"", nxtName)
 	}
 	// Colorize
 	if opts.Colorize {
-		nxtName = ANSIColor(nxt, nxtName)
+		nxtName = opts.colorize(nxt, nxtName)
 	}
 	// Don't do classify here, because it's always a dir/symlink-to-dir
-	name = filepath.Join(name, nxtName)
+	if opts.PathSep == "" {
+		name = filepath.Join(name, nxtName)
+	} else {
+		name = name + opts.PathSep + nxtName
+	}
 	return joinSingleNodes(opts, nxt, name)
 }
 
+// rootName returns the path shown for the node, which is "." for the root
+// when it's the current dir. and DotRoot is set.
+func rootName(opts *Options, node *Node) string {
+	if !opts.DotRoot || node.depth != 0 {
+		return displayPath(opts, node.path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return displayPath(opts, node.path)
+	}
+	if path, err := filepath.Abs(node.path); err == nil && path == wd {
+		return "."
+	}
+	return displayPath(opts, node.path)
+}
+
+// displayPath returns the path with the PathSep separator, if set.
+func displayPath(opts *Options, path string) string {
+	if opts.PathSep == "" {
+		return path
+	}
+	return strings.Replace(path, string(filepath.Separator), opts.PathSep, -1)
+}
+
+// octalMode returns the permissions, and setuid/setgid/sticky bits, in
+// octal like chmod.
+func octalMode(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
 // classify returns the suffix for a path entry name
 func classify(node *Node) string {
 	var mode = node.Mode()
@@ -563,11 +1243,16 @@ func FormatSize(opts *Options, size int64) string {
 	return fmt.Sprintf("%11d", size)
 }
 
-type maxTreeValues struct {
-	mIno int
-	mDev int
-	mUid int
-	mGid int
+// Layout is the widths of the property columns, so that all the lines of a
+// tree line up. It's the first pass of printing, see NewLayout.
+type Layout struct {
+	Inode  int
+	Device int
+	Links  int
+	Uid    int
+	Gid    int
+	Label  int  // The SELinux contexts
+	ACL    bool // An entry has an ACL, so the modes have a + or space
 }
 
 // numLen is a quick hack to do math.Log10(num) + 1
@@ -580,11 +1265,16 @@ func numLen(num uint64) int {
 	return ret
 }
 
+// idCacheMu locks uidCache and gidCache, as they can be used while visiting.
+var idCacheMu sync.Mutex
+
 // uidCache cache the user.LookupId calls as it opens the file for each call!
 var uidCache map[uint64]string
 
 // uidConvert takes a uid and returns the name
 func uidConvert(uid uint64, lookup bool) string {
+	idCacheMu.Lock()
+	defer idCacheMu.Unlock()
 	if v, ok := uidCache[uid]; ok {
 		return v
 	}
@@ -613,6 +1303,8 @@ var gidCache map[uint64]string
 
 // gidConvert takes a gid and returns the name
 func gidConvert(gid uint64, lookup bool) string {
+	idCacheMu.Lock()
+	defer idCacheMu.Unlock()
 	if v, ok := gidCache[gid]; ok {
 		return v
 	}
@@ -635,9 +1327,26 @@ func gidConvert(gid uint64, lookup bool) string {
 	return gidCache[gid]
 }
 
-// setupMaxValues walk the entire tree and get the max values. We currently
-// walk the nodes even if we don't print them ... but eh.
-func (node *Node) setupMaxValues(opts *Options, maxvals *maxTreeValues) {
+// NewLayout returns the Layout for the tree as Print shows it, the dirs.
+// cut off by DeepLevel or the dynamic leveling aren't looked at.
+func NewLayout(opts *Options, node *Node) *Layout {
+	layout := &Layout{}
+	layout.addPrinted(opts, node, 0)
+	return layout
+}
+
+// Add widens the columns of the layout to fit the node, for renderers that
+// do their own pass over the tree.
+func (layout *Layout) Add(opts *Options, node *Node) {
+	if opts.FileMode && !layout.ACL && hasACL(node) {
+		layout.ACL = true
+	}
+	if opts.Context {
+		if nctx := len(securityContext(opts, node)); nctx > layout.Label {
+			layout.Label = nctx
+		}
+	}
+
 	ok, inode, device, uid, gid := getStat(node)
 	if !ok {
 		return
@@ -645,81 +1354,130 @@ func (node *Node) setupMaxValues(opts *Options, maxvals *maxTreeValues) {
 
 	if opts.Inodes {
 		nino := numLen(inode)
-		if nino > maxvals.mIno {
-			maxvals.mIno = nino
+		if nino > layout.Inode {
+			layout.Inode = nino
 		}
 	}
 
 	if opts.Device {
 		ndev := numLen(device)
-		if ndev > maxvals.mDev {
-			maxvals.mDev = ndev
+		if ndev > layout.Device {
+			layout.Device = ndev
+		}
+	}
+
+	if opts.LinkCount {
+		_, nlink := getNlink(node)
+		if nlinks := numLen(nlink); nlinks > layout.Links {
+			layout.Links = nlinks
 		}
 	}
 
 	if opts.ShowUid {
 		nuid := len(uidConvert(uid, !opts.NumericIDs))
-		if nuid > maxvals.mUid {
-			maxvals.mUid = nuid
+		if nuid > layout.Uid {
+			layout.Uid = nuid
 		}
 	}
 
 	if opts.ShowGid {
 		ngid := len(gidConvert(gid, !opts.NumericIDs))
-		if ngid > maxvals.mGid {
-			maxvals.mGid = ngid
+		if ngid > layout.Gid {
+			layout.Gid = ngid
 		}
 	}
+}
 
+// addPrinted adds the nodes that print shows, following the same cutoffs.
+func (layout *Layout) addPrinted(opts *Options, node *Node, cutoff int64) {
+	layout.Add(opts, node)
+	if node.err != nil {
+		return
+	}
+	node, _ = joinSingleNodes(opts, node, "")
+	cutoff, ok := node.childCutoff(opts, cutoff)
+	if !ok {
+		return
+	}
 	for _, nnode := range node.nodes {
-		nnode.setupMaxValues(opts, maxvals)
+		layout.addPrinted(opts, nnode, cutoff)
 	}
 }
 
-func (node *Node) print(opts *Options, indentc, indentn string,
-	cutoff int64, maxvals *maxTreeValues) {
-	if node.err != nil {
-		err := node.err.Error()
-		if msgs := strings.Split(err, ": "); len(msgs) > 1 {
-			err = msgs[1]
-		}
-		fmt.Printf("%s [%s]\n", node.path, err)
+// addLevels adds the node and everything under it, down to DeepLevel. For
+// the outputs without dynamic leveling.
+func (layout *Layout) addLevels(opts *Options, node *Node) {
+	layout.Add(opts, node)
+	if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
 		return
 	}
-
-	if maxvals == nil {
-		maxvals = &maxTreeValues{}
-		node.setupMaxValues(opts, maxvals)
+	for _, nnode := range node.nodes {
+		layout.addLevels(opts, nnode)
 	}
+}
 
+// Props returns the formatted property columns for the node, based on the
+// given configuration and padded for the layout.
+func (node *Node) Props(opts *Options, layout *Layout) []string {
 	var props []string
 	ok, inode, device, uid, gid := getStat(node)
 	// inodes
 	if ok && opts.Inodes {
-		props = append(props, fmt.Sprintf("%*d", maxvals.mIno, inode))
+		props = append(props, fmt.Sprintf("%*d", layout.Inode, inode))
 	}
 	// device
 	if ok && opts.Device {
-		props = append(props, fmt.Sprintf("%*d", maxvals.mDev, device))
+		props = append(props, fmt.Sprintf("%*d", layout.Device, device))
+	}
+	// hard links
+	if ok && opts.LinkCount {
+		_, nlink := getNlink(node)
+		props = append(props, fmt.Sprintf("%*d", layout.Links, nlink))
 	}
 	// Mode
+	if opts.OctalMode {
+		props = append(props, octalMode(node.Mode()))
+	}
 	if opts.FileMode {
-		props = append(props, node.Mode().String())
+		mode := node.Mode().String()
+		if hasACL(node) {
+			mode += "+"
+		} else if layout.ACL {
+			mode += " "
+		}
+		props = append(props, mode)
+	}
+	if opts.Xattrs {
+		mark := xattrMark(node)
+		if opts.FileMode || opts.OctalMode {
+			props[len(props)-1] += mark
+		} else {
+			props = append(props, mark)
+		}
 	}
 	// Owner/Uid
 	if ok && opts.ShowUid {
 		uidStr := uidConvert(uid, !opts.NumericIDs)
-		props = append(props, fmt.Sprintf("%-*s", maxvals.mUid, uidStr))
+		props = append(props, fmt.Sprintf("%-*s", layout.Uid, uidStr))
 	}
 	// Group/Gid
 	if ok && opts.ShowGid {
 		gidStr := gidConvert(gid, !opts.NumericIDs)
-		props = append(props, fmt.Sprintf("%-*s", maxvals.mGid, gidStr))
+		props = append(props, fmt.Sprintf("%-*s", layout.Gid, gidStr))
+	}
+	// SELinux
+	if opts.Context {
+		props = append(props, fmt.Sprintf("%-*s", layout.Label,
+			securityContext(opts, node)))
 	}
 	// Size
 	if !node.IsDir() {
 		if opts.ByteSize || opts.UnitSize {
-			props = append(props, FormatSize(opts, node.Size()))
+			size := FormatSize(opts, node.Size())
+			if opts.Allocated {
+				size += "/" + FormatSize(opts, DiskUsage(node))
+			}
+			props = append(props, size)
 		}
 	} else {
 		if opts.ByteSize || opts.UnitSize {
@@ -727,48 +1485,134 @@ func (node *Node) print(opts *Options, indentc, indentn string,
 
 			rsize, err := DirRecursiveSize(node)
 
-			if err != nil && rsize <= 0 {
+			if node.shallow { // Streaming, so the size isn't known
+				size = fmt.Sprintf("%*s", len(FormatSize(opts, 0)), "")
+			} else if err != nil && rsize <= 0 {
 				if opts.UnitSize {
 					size = "????"
 				} else {
 					size = "???????????"
 				}
+			} else if rsize == 0 && opts.HideEmptySize {
+				size = fmt.Sprintf("%*s", len(FormatSize(opts, 0)),
+					opts.EmptySizeText)
 			} else {
 				size = FormatSize(opts, rsize)
 			}
+			if opts.Allocated {
+				if node.shallow {
+					size += fmt.Sprintf(" %*s", len(FormatSize(opts, 0)), "")
+				} else {
+					size += "/" + FormatSize(opts, DiskUsage(node))
+				}
+			}
 			props = append(props, size)
 		}
 	}
+	// Content type
+	if opts.ShowContent {
+		props = append(props, node.ctype.String())
+	}
+	// Checksum
+	if checksumLen(opts.Checksum) > 0 {
+		props = append(props, fmt.Sprintf("%-*s", checksumLen(opts.Checksum),
+			node.csum))
+	}
 	// Last modification
 	if opts.LastMod {
-		props = append(props, node.ModTime().Format("2006-01-02 15:04"))
+		mtime := node.ModTime()
+		if opts.MtimeRollup {
+			mtime = NewestModTime(node)
+		}
+		props = append(props, formatModTime(opts, mtime))
 	}
-	// Print properties
-	var psize int
-	if len(props) == 1 {
-		psize, _ = fmt.Fprintf(opts.OutFile, "%s ", strings.Join(props, " "))
-	} else if len(props) > 0 {
-		psize, _ = fmt.Fprintf(opts.OutFile, "[%s] ", strings.Join(props, " "))
+	// Birth time
+	if opts.BirthTime {
+		if node.btime.IsZero() {
+			width := len(formatModTime(opts, time.Now()))
+			props = append(props, fmt.Sprintf("%*s", width, ""))
+		} else {
+			props = append(props, formatModTime(opts, node.btime))
+		}
 	}
+	// Access time
+	if opts.AccessTime {
+		if atime, ok := getAccessTime(node); ok {
+			props = append(props, formatModTime(opts, atime))
+		} else {
+			width := len(formatModTime(opts, time.Now()))
+			props = append(props, fmt.Sprintf("%*s", width, ""))
+		}
+	}
+	return props
+}
+
+// formatter returns the Formatter to use for the text output.
+func (opts *Options) formatter() Formatter {
+	if opts.Template != nil {
+		return templateFormatter{opts.Template}
+	}
+	if opts.Formatter != nil {
+		return opts.Formatter
+	}
+	return TextFormatter{}
+}
+
+// printLine prints the line for the node, and returns the node to print the
+// children of (JoinSingle can skip dirs.) and its props, or nil for errors.
+func (node *Node) printLine(opts *Options, indentc string,
+	layout *Layout) (*Node, []string) {
+	if node.err != nil {
+		err := node.err.Error()
+		if msgs := strings.Split(err, ": "); len(msgs) > 1 {
+			err = msgs[1]
+		}
+		w := opts.Errors
+		if w == nil {
+			w = opts.OutFile
+		}
+		path := node.path
+		if opts.ASCIINames {
+			path = asciiName(path)
+		}
+		fmt.Fprintf(w, "%s [%s]\n", path, err)
+		return nil, nil
+	}
+
+	props := node.Props(opts, layout)
+	fmtr := opts.formatter()
 	// name/path
 	var name string
 	if node.depth == 0 || opts.FullPath {
-		name = node.path
+		name = rootName(opts, node)
 	} else {
 		name = node.Name()
 	}
+	if opts.ASCIINames {
+		name = asciiName(name)
+	}
 
 	// Quotes
-	if opts.Quotes {
+	if opts.Quotes || (opts.QuoteRoot && node.depth == 0) {
 		name = strconv.Quote(name)
 	}
 	// Colorize
 	if opts.Colorize {
-		name = ANSIColor(node, name)
+		name = opts.colorize(node, name)
 	}
 
 	// Do the github thing...
+	jnode := node
 	node, name = joinSingleNodes(opts, node, name)
+	if opts.JoinCounts && jnode != node {
+		size, _ := DirRecursiveSize(jnode)
+		name += fmt.Sprintf(" [%d files, %s]",
+			dirRecursiveFiles(jnode), formatBytes(size))
+	}
+	// Hyperlink, to the last of any joined dirs.
+	if opts.Hyperlink && !node.virtual {
+		name = hyperlink(node.path, name)
+	}
 
 	// Classify
 	if opts.Classify {
@@ -777,24 +1621,35 @@ func (node *Node) print(opts *Options, indentc, indentn string,
 
 	// IsSymlink
 	if node.Mode()&os.ModeSymlink == os.ModeSymlink {
-		vtarget, err := os.Readlink(node.path)
-		if err != nil {
+		vtarget, rerr := readlink(opts, node.path)
+		if rerr != nil {
 			vtarget = node.path
 		}
 		targetPath, err := filepath.EvalSymlinks(node.path)
 		if err != nil {
 			targetPath = vtarget
+			if rerr == nil && !filepath.IsAbs(vtarget) {
+				targetPath = filepath.Join(filepath.Dir(node.path), vtarget)
+			}
 		}
 		fi, err := opts.Fs.Stat(targetPath)
+		if opts.ASCIINames {
+			vtarget = asciiName(vtarget)
+		}
 		if opts.Colorize && fi != nil {
-			vtarget = ANSIColor(&Node{FileInfo: fi, path: vtarget}, vtarget)
+			vtarget = opts.colorize(&Node{FileInfo: fi, path: vtarget}, vtarget)
 		}
 		name = fmt.Sprintf("%s -> %s", name, vtarget)
+		if fi == nil && !node.virtual && opts.Compat == "" {
+			name += " [broken]"
+		} else if fi != nil && opts.LinkTargets {
+			name += linkTargetText(opts, fi)
+		}
 		// Follow symbolic links like directories
 		if opts.FollowLink {
 			path, err := filepath.Abs(targetPath)
 			if err == nil && fi != nil && fi.IsDir() {
-				if _, ok := node.vpaths[filepath.Clean(path)]; !ok {
+				if node.vpaths == nil || !node.vpaths.has(filepath.Clean(path)) {
 					inf := &Node{FileInfo: fi, path: targetPath}
 					inf.vpaths = node.vpaths
 					inf.Visit(opts)
@@ -805,8 +1660,33 @@ func (node *Node) print(opts *Options, indentc, indentn string,
 			}
 		}
 	}
-	fmt.Fprintf(opts.OutFile, "%s%s\n", indentc, name)
+	// Policy violations
+	if opts.Policy != nil && node.policy != "" {
+		name += " [" + node.policy + "]"
+	}
+	if opts.Savings && node.saving != "" {
+		name += " [" + node.saving + "]"
+	} else if opts.Sparse && node.sparse {
+		name += " [sparse]"
+	}
+	if node.partial {
+		name += " [interrupted]"
+	}
+	if opts.XattrNames {
+		name += xattrText(node)
+	}
+	line := fmtr.FormatLine(node, indentc, name, props)
+	if _, ok := fmtr.(TextFormatter); ok {
+		line = fitLine(opts, line, indentc, props)
+	}
+	fmt.Fprintln(opts.OutFile, line)
+	return node, props
+}
 
+// childCutoff returns the cutoff for the children of the node, and false
+// if they aren't printed because of DeepLevel or the dynamic leveling. The
+// cutoff line is printed for the latter, unless DeepLevel is 1.
+func (node *Node) childCutoff(opts *Options, cutoff int64) (int64, bool) {
 	deepLevel := opts.DeepLevel
 	if deepLevel > 0 && node.depth >= deepLevel {
 		// This should only be true when viewing UnitSize/ByteSize data.
@@ -816,7 +1696,7 @@ func (node *Node) print(opts *Options, indentc, indentn string,
 		cutoff = 1
 		// But only if Level > 1, otherwise it can be a bit too spammy.
 		if opts.DeepLevel == 1 {
-			return
+			return 0, false
 		}
 	}
 
@@ -829,10 +1709,7 @@ func (node *Node) print(opts *Options, indentc, indentn string,
 	} else if deepLevel == -1 && node.IsDir() {
 		children := dirDirectChildren1(node)
 		if children > cutoff || opts.DeepLevel != -1 {
-			recChildren, _ := dirRecursiveChildren(opts, node)
-			p := message.NewPrinter(language.Make(os.Getenv("LANG")))
-			p.Fprintf(opts.OutFile, "%*s%s%s[%d file(s)]\n", psize, "", indentn, "┖┄ ", recChildren)
-			return
+			return cutoff, false
 		}
 
 		if children >= cutoff {
@@ -841,22 +1718,46 @@ func (node *Node) print(opts *Options, indentc, indentn string,
 			cutoff -= children
 		}
 	}
+	return cutoff, true
+}
+
+func (node *Node) print(opts *Options, indentc, indentn string,
+	cutoff int64, layout *Layout) {
+	if layout == nil {
+		layout = NewLayout(opts, node)
+	}
+
+	node, props := node.printLine(opts, indentc, layout)
+	if node == nil {
+		return
+	}
+
+	cutoff, ok := node.childCutoff(opts, cutoff)
+	if !ok {
+		if opts.DeepLevel != 1 {
+			recChildren, _ := dirRecursiveChildren(opts, node)
+			fmt.Fprintln(opts.OutFile, opts.formatter().FormatCutoff(node,
+				indentn+opts.graphics().Cutoff, props, recChildren))
+		}
+		return
+	}
 
 	// Print tree structure
 	// the main idea of the print logic came from here: github.com/campoy/tools/tree
-	add := "┃ "
+	g := opts.graphics()
 	for i, nnode := range node.sortedNodes(opts) {
+		add := g.Vertical
 		if opts.NoIndent {
 			add = ""
 		} else {
 			if i == len(node.nodes)-1 {
-				indentc = indentn + "┗━ "
-				add = "  "
+				indentc = indentn + g.Last
+				add = g.Space
 			} else {
-				indentc = indentn + "┣━ "
+				indentc = indentn + g.Branch
 			}
 		}
 
-		nnode.print(opts, indentc, indentn+add, cutoff, maxvals)
+		nnode.print(opts, indentc, indentn+add, cutoff, layout)
 	}
 }
@@ -1,9 +1,18 @@
 package tree
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
+	"text/template"
 	"time"
 )
 
@@ -265,8 +274,42 @@ root
 ┣━ b
 ┗━ c
   ┗━ d
+`, 1, 3},
+	{"dirs-last sort", &Options{Fs: fs, OutFile: out, DirLast: true, SizeSort: true}, `
+root
+┣━ a
+┣━ b
+┗━ c
+  ┗━ d
+`, 1, 3},
+	{"count-sort", &Options{Fs: fs, OutFile: out, CountSort: true, ReverSort: true}, `
+root
+┣━ c
+┃ ┗━ d
+┣━ b
+┗━ a
+`, 1, 3},
+	{"sort-by", &Options{Fs: fs, OutFile: out, SortBy: SortByKey(scoreKey)}, `
+root
+┣━ b
+┣━ c
+┃ ┗━ d
+┗━ a
+`, 1, 3},
+	{"reverse sort-by", &Options{Fs: fs, OutFile: out, ReverSort: true,
+		SortBy: SortByKey(scoreKey)}, `
+root
+┣━ a
+┣━ c
+┃ ┗━ d
+┗━ b
 `, 1, 3}}
 
+// scoreKey is a SortByKey key, like a score from a database
+func scoreKey(n *Node) float64 {
+	return map[string]float64{"a": 3, "b": 1, "c": 2}[n.Name()]
+}
+
 func TestSort(t *testing.T) {
 	tFmt := "2006-Jan-02"
 	aTime, _ := time.Parse(tFmt, "2015-Aug-01")
@@ -330,6 +373,12 @@ daemon ┗━ c
 -rw-r--r-- ┣━ a
 -rwxr-xr-x ┣━ b
 -rw-rw-rw- ┗━ c
+`, 0, 3},
+	{"octal-mode", &Options{Fs: fs, OutFile: out, OctalMode: true}, `
+0000 root
+0644 ┣━ a
+0755 ┣━ b
+0666 ┗━ c
 `, 0, 3},
 	{"lastMod", &Options{Fs: fs, OutFile: out, LastMod: true}, `
 0001-01-01 00:00 root
@@ -366,6 +415,146 @@ func TestGraphics(t *testing.T) {
 	}
 }
 
+func TestCharset(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b"}, {name: "c"}}},
+		{name: "d"},
+	}}
+	fs.clean().addFile(root.name, root)
+	custom, err := ParseGraphics("+ ,` ,: ,  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseGraphics("+,`"); err == nil {
+		t.Errorf("expected an error for 2 strings")
+	}
+	for _, test := range []struct {
+		g        *Graphics
+		expected string
+	}{
+		{&ASCIIGraphics, "root\n|-- a\n|   |-- b\n|   \\-- c\n\\-- d\n"},
+		{custom, "root\n+ a\n: + b\n: ` c\n` d\n"},
+	} {
+		out.clear()
+		opts := &Options{Fs: fs, OutFile: out, Graphics: test.g}
+		inf := New(root.name)
+		inf.Visit(opts)
+		inf.Print(opts)
+		if !out.equal(test.expected) {
+			t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, test.expected)
+		}
+	}
+}
+
+func TestAllocated(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", size: 5000, stat: &syscall.Stat_t{Blocks: 16}},
+		{name: "sparse", size: 9000, stat: &syscall.Stat_t{Blocks: 0}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, ByteSize: true, Allocated: true}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `      14000/       8192 root
+       5000/       8192 ┣━ a
+       9000/          0 ┗━ sparse
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestSavings(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", size: 5000, stat: &syscall.Stat_t{Blocks: 16}},
+		{name: "small", size: 100, stat: &syscall.Stat_t{Blocks: 0}},
+		{name: "sparse", size: 10240, stat: &syscall.Stat_t{Blocks: 4}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, Savings: true}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Saved != 8192 {
+		t.Errorf("expected 8192 saved, got %d", sum.Saved)
+	}
+	expected := `root
+┣━ a
+┣━ small
+┗━ sparse [sparse/compressed, 80% saved]
+
+0 directories, 3 files, 8,192 saved by sparse/compressed files
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+
+	// An annotation with the same name isn't counted
+	out.clear()
+	opts = &Options{Fs: fs, OutFile: out, NoReport: true}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			node.Annotate("saving", "mine")
+			return next(opts, node)
+		}
+	}
+	sum, _ = Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if sum.Saved != 0 || !out.equal("root\n┣━ a\n┣━ small\n┗━ sparse\n") {
+		t.Errorf("annotation: got %d saved:\n%+v", sum.Saved, out.str)
+	}
+}
+
+func TestSparse(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", size: 5000, stat: &syscall.Stat_t{Blocks: 16}},
+		{name: "sparse", size: 10240, stat: &syscall.Stat_t{Blocks: 4}},
+		{name: "sub", files: []*file{
+			{name: "hidden", size: 10240, stat: &syscall.Stat_t{Blocks: 8}},
+		}},
+	}}
+	fs.clean().addFile(root.name, root)
+	// An annotation with the same name isn't a sparse file
+	opts := &Options{Fs: fs, OutFile: out, Sparse: true}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			node.Annotate("sparse", "mine")
+			return next(opts, node)
+		}
+	}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `root
+┣━ a
+┣━ sparse [sparse]
+┗━ sub
+  ┗━ hidden [sparse]
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+
+	// The disk usage of the dirs includes the files hidden by DirsOnly
+	out.clear()
+	opts = &Options{Fs: fs, OutFile: out, DirsOnly: true, ByteSize: true,
+		Allocated: true}
+	inf = New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected = `      25480/      14336 root
+      10240/       4096 ┗━ sub
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
 var symlinkTests = []treeTest{
 	{"symlink", &Options{Fs: fs, OutFile: out}, `
 root
@@ -444,3 +633,1513 @@ func TestCount(t *testing.T) {
 		t.Errorf("TestCount - expect (dir, file) count to be equal to (7, 8)\n%s", out.str)
 	}
 }
+
+func TestMultiRoot(t *testing.T) {
+	rootA := &file{
+		name:  "a",
+		files: []*file{{name: "b"}, {name: "c", files: []*file{{name: "d"}}}},
+	}
+	rootE := &file{
+		name:  "e",
+		files: []*file{{name: "f"}, {name: "g"}, {name: "h"}},
+	}
+	fs.clean().addFile(rootA.name, rootA).addFile(rootE.name, rootE)
+	opts := &Options{Fs: fs, OutFile: out}
+	infA, infE := New(rootA.name), New(rootE.name)
+	var wg sync.WaitGroup
+	var dA, fA, dE, fE int
+	wg.Add(2)
+	go func() { defer wg.Done(); dA, fA = infA.Visit(opts) }()
+	go func() { defer wg.Done(); dE, fE = infE.Visit(opts) }()
+	wg.Wait()
+	if dA != 1 || fA != 2 {
+		t.Errorf("TestMultiRoot - expect (dir, file) count for a to be (1, 2), got (%d, %d)", dA, fA)
+	}
+	if dE != 0 || fE != 3 {
+		t.Errorf("TestMultiRoot - expect (dir, file) count for e to be (0, 3), got (%d, %d)", dE, fE)
+	}
+}
+
+// depthFormatter is a custom Formatter for the tests
+type depthFormatter struct{}
+
+func (depthFormatter) FormatLine(node *Node, indent, name string, props []string) string {
+	return fmt.Sprintf("%d %s %v", node.depth, name, props)
+}
+func (depthFormatter) FormatCutoff(node *Node, indent string, props []string, files int64) string {
+	return fmt.Sprintf("%d ... %d", node.depth+1, files)
+}
+
+func TestFormatter(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 1},
+			{name: "b", size: 2, files: []*file{{name: "c", size: 2}}},
+		},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, ByteSize: true, Formatter: depthFormatter{}}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `0 root [          3]
+1 a [          1]
+1 b [          2]
+2 c [          2]
+`
+	if !out.equal(expected) {
+		t.Errorf("formatter:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestA11yFormatter(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 1},
+			{name: "b", size: 2, files: []*file{{name: "c", size: 2}}},
+		},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, ByteSize: true, Formatter: A11yFormatter{}}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `level 0: root (3)
+level 1: a (1)
+level 1: b (2)
+level 2: b/c (2)
+`
+	if !out.equal(expected) {
+		t.Errorf("a11y:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestNewLayout(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", stat: &syscall.Stat_t{Ino: 1}, files: []*file{
+		{name: "a", stat: &syscall.Stat_t{Ino: 22}, files: []*file{
+			{name: "b", stat: &syscall.Stat_t{Ino: 333333}},
+		}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, Inodes: true, ByteSize: true, DeepLevel: 1}
+	inf := New(root.name)
+	inf.Visit(opts)
+	if layout := NewLayout(opts, inf); layout.Inode != 2 {
+		t.Errorf("layout: expected inode width 2 for the printed entries, got %d", layout.Inode)
+	}
+	inf.Print(opts)
+	expected := `[ 1           0] root
+[22           0] ┗━ a
+`
+	if !out.equal(expected) {
+		t.Errorf("layout:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestLinkCount(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", stat: &syscall.Stat_t{Ino: 1, Nlink: 3}, files: []*file{
+		{name: "a", stat: &syscall.Stat_t{Ino: 2, Nlink: 12}},
+		{name: "b", stat: &syscall.Stat_t{Ino: 3, Nlink: 1}, files: []*file{
+			{name: "c", stat: &syscall.Stat_t{Ino: 4, Nlink: 1}},
+		}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, LinkCount: true, JoinSingle: true}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := ` 3 root
+12 ┣━ a
+ 1 ┗━ b
+ 1   ┗━ c
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+// xattrFs is a XattrFs, with the xattrs for the paths
+type xattrFs struct {
+	Fs
+	xattrs map[string][]string
+}
+
+func (xfs xattrFs) Listxattr(path string) ([]string, error) {
+	return xfs.xattrs[path], nil
+}
+
+// xattrValueFs is a xattrFs with the values too, for the "name=value"
+// xattrs.
+type xattrValueFs struct {
+	xattrFs
+}
+
+func (xfs xattrValueFs) Getxattr(path, name string) ([]byte, error) {
+	for _, xattr := range xfs.xattrs[path] {
+		if i := strings.Index(xattr, "="); i != -1 && xattr[:i] == name {
+			return []byte(xattr[i+1:] + "\x00"), nil
+		}
+	}
+	return nil, nil
+}
+
+func TestXattrs(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", mode: os.ModeDir | 0755, files: []*file{
+		{name: "a", mode: 0644},
+		{name: "b", mode: 0600},
+	}}
+	fs.clean().addFile(root.name, root)
+	xfs := xattrFs{fs, map[string][]string{
+		"root/a": {"user.x", "user.y"},
+		"root/b": {"system.posix_acl_access"},
+	}}
+	cfs := xattrValueFs{xattrFs{fs, map[string][]string{
+		"root/a": {"security.selinux=user_u:object_r:tmp_t:s0"},
+	}}}
+	for _, test := range []struct {
+		name     string
+		opts     *Options
+		expected string
+	}{
+		{"marks", &Options{Fs: xfs, OutFile: out, FileMode: true, Xattrs: true}, `drwxr-xr-x   root
+-rw-r--r-- @ ┣━ a
+-rw-------+  ┗━ b
+`},
+		{"acl", &Options{Fs: xfs, OutFile: out, FileMode: true}, `drwxr-xr-x  root
+-rw-r--r--  ┣━ a
+-rw-------+ ┗━ b
+`},
+		{"names", &Options{Fs: xfs, OutFile: out, XattrNames: true}, `root
+┣━ a [xattrs: user.x, user.y]
+┗━ b [xattrs: system.posix_acl_access]
+`},
+		{"context", &Options{Fs: cfs, OutFile: out, Context: true}, `?                        root
+user_u:object_r:tmp_t:s0 ┣━ a
+?                        ┗━ b
+`},
+		{"no-values", &Options{Fs: cfs.xattrFs, OutFile: out, Context: true}, `? root
+? ┣━ a
+? ┗━ b
+`},
+	} {
+		out.clear()
+		inf := New(root.name)
+		inf.Visit(test.opts)
+		inf.Print(test.opts)
+		if !out.equal(test.expected) {
+			t.Errorf("%s: got:\n%+v\nexpected:\n%+v", test.name, out.str, test.expected)
+		}
+	}
+
+	// The names are read once for each entry, while visiting
+	lfs := &listCounter{xattrFs: xfs}
+	opts := &Options{Fs: lfs, OutFile: out, FileMode: true, Xattrs: true}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	if lfs.calls != 3 {
+		t.Errorf("Listxattr called %d times, expected 3", lfs.calls)
+	}
+}
+
+// listCounter counts the Listxattr calls of the xattrFs
+type listCounter struct {
+	xattrFs
+	calls int32
+}
+
+func (lfs *listCounter) Listxattr(path string) ([]string, error) {
+	atomic.AddInt32(&lfs.calls, 1)
+	return lfs.xattrFs.Listxattr(path)
+}
+
+func TestHardLinks(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{
+			{name: "x", size: 100, stat: &syscall.Stat_t{Ino: 5, Nlink: 2}},
+		}},
+		{name: "b", files: []*file{
+			{name: "y", size: 100, stat: &syscall.Stat_t{Ino: 5, Nlink: 2}},
+			{name: "z", size: 10, stat: &syscall.Stat_t{Ino: 6, Nlink: 1}},
+		}},
+	}}
+	fs.clean().addFile(root.name, root)
+	for _, test := range []struct {
+		name     string
+		opts     *Options
+		expected string
+	}{
+		{"once", &Options{Fs: fs, OutFile: out, ByteSize: true}, `        110 root
+        100 ┣━ a
+        100 ┃ ┗━ x
+         10 ┗━ b
+        100   ┣━ y
+         10   ┗━ z
+`},
+		{"count-links", &Options{Fs: fs, OutFile: out, ByteSize: true,
+			CountLinks: true}, `        210 root
+        100 ┣━ a
+        100 ┃ ┗━ x
+        110 ┗━ b
+        100   ┣━ y
+         10   ┗━ z
+`},
+		{"dirs-only", &Options{Fs: fs, OutFile: out, ByteSize: true,
+			DirsOnly: true}, `        110 root
+        100 ┣━ a
+         10 ┗━ b
+`},
+	} {
+		out.clear()
+		inf := New(root.name)
+		inf.Visit(test.opts)
+		inf.Print(test.opts)
+		if !out.equal(test.expected) {
+			t.Errorf("%s: got:\n%+v\nexpected:\n%+v", test.name, out.str, test.expected)
+		}
+	}
+
+	// Streaming has no dir. sizes, but the report counts each file once
+	for _, test := range []struct {
+		name     string
+		opts     *Options
+		expected int64
+	}{
+		{"stream", &Options{Fs: fs, OutFile: out, Stream: true}, 110},
+		{"stream-dirs-only", &Options{Fs: fs, OutFile: out, Stream: true,
+			DirsOnly: true}, 110},
+		{"stream-count-links", &Options{Fs: fs, OutFile: out, Stream: true,
+			CountLinks: true}, 210},
+	} {
+		out.clear()
+		sum, err := Run(context.Background(),
+			RunConfig{Options: test.opts, Paths: []string{root.name}})
+		if err != nil || sum.Bytes != test.expected {
+			t.Errorf("%s: got %d (%v), expected %d", test.name, sum.Bytes, err,
+				test.expected)
+		}
+	}
+
+	// The stats count the files once too
+	opts := &Options{Fs: fs, OutFile: out}
+	inf := New(root.name)
+	inf.Visit(opts)
+	st := TreeStats(10, inf)
+	if st.Files != 3 || st.Bytes != 110 || st.Sizes[1].Files != 2 ||
+		len(st.Largest) != 2 || len(st.Exts) != 1 || st.Exts[0].Bytes != 110 {
+		t.Errorf("stats: got %+v", st)
+	}
+	for _, opts := range []*Options{
+		{Fs: fs, OutFile: out, Stats: true, Stream: true},
+		{Fs: fs, OutFile: out, StatsOnly: true, JSON: true},
+	} {
+		conf := RunConfig{Options: opts, Paths: []string{root.name}}
+		if _, err := Run(context.Background(), conf); err == nil {
+			t.Errorf("stats: expected an error (stream %v)", opts.Stream)
+		}
+	}
+}
+
+func TestBirthTime(t *testing.T) {
+	defer out.clear()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	root := &file{name: "root", lastMod: mtime, files: []*file{
+		{name: "a", lastMod: mtime},
+	}}
+	fs.clean().addFile(root.name, root)
+	// The mock files have no birth times, so the column is blank
+	opts := &Options{Fs: fs, OutFile: out, LastMod: true, BirthTime: true,
+		TimeFormat: "2006-01-02"}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `[2020-01-02           ] root
+[2020-01-02           ] ┗━ a
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+
+	nodes := Nodes{
+		{path: "b", btime: mtime.Add(time.Hour)},
+		{path: "c"},
+		{path: "a", btime: mtime},
+	}
+	sort.Stable(ByFunc{nodes, BTimeSort})
+	if nodes[0].path != "c" || nodes[1].path != "a" || nodes[2].path != "b" {
+		t.Errorf("btime sort: got %s %s %s", nodes[0].path, nodes[1].path,
+			nodes[2].path)
+	}
+}
+
+func TestVisitWrapper(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "b", files: []*file{{name: "c"}}},
+			{name: "d"},
+		},
+	}
+	fs.clean().addFile(root.name, root)
+	var mu sync.Mutex
+	var visited int
+	opts := &Options{Fs: fs, OutFile: out}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			mu.Lock()
+			visited++
+			mu.Unlock()
+			if node.path == "root/b" {
+				return 0, 0, SkipNode
+			}
+			return next(opts, node)
+		}
+	}
+	inf := New(root.name)
+	d, f := inf.Visit(opts)
+	if d != 0 || f != 2 || visited != 4 {
+		t.Errorf("TestVisitWrapper - expect (dir, file, visited) count to be (0, 2, 4), got (%d, %d, %d)", d, f, visited)
+	}
+	inf.Print(opts)
+	expected := `root
+┣━ a
+┗━ d
+`
+	if !out.equal(expected) {
+		t.Errorf("visit-wrapper:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 1},
+			{name: "b", files: []*file{{name: "c", size: 2}}},
+		},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, NDJSON: true, NoSort: true}
+	inf := New(root.name)
+	d, f := inf.Visit(opts)
+	if d != 1 || f != 2 {
+		t.Errorf("TestNDJSON - expect (dir, file) count to be (1, 2), got (%d, %d)", d, f)
+	}
+	if len(inf.nodes) != 0 {
+		t.Errorf("TestNDJSON - expect no nodes to be kept, got %d", len(inf.nodes))
+	}
+	lines := strings.Split(strings.TrimSuffix(out.str, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("TestNDJSON - expect 4 lines, got:\n%s", out.str)
+	}
+	var ent ndjsonEntry
+	if err := json.Unmarshal([]byte(lines[0]), &ent); err != nil {
+		t.Fatal(err)
+	}
+	if ent.Path != "root" || ent.Depth != 0 {
+		t.Errorf("TestNDJSON - expect root first, got: %s", lines[0])
+	}
+	if !strings.Contains(out.str, `"path":"root/b/c","depth":2,"size":2`) {
+		t.Errorf("TestNDJSON - missing root/b/c, got:\n%s", out.str)
+	}
+}
+
+func TestInject(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name:  "root",
+		files: []*file{{name: "a"}, {name: "c", files: []*file{{name: "d"}}}},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out}
+	opts.Inject = func(dir *Node) Nodes {
+		if dir.path != "root" {
+			return nil
+		}
+		vdir := NewVirtual(VirtualInfo("b", 0, os.ModeDir, time.Time{}),
+			NewVirtual(VirtualInfo("and 3 more", 0, 0, time.Time{})))
+		return Nodes{vdir}
+	}
+	inf := New(root.name)
+	d, f := inf.Visit(opts)
+	if d != 2 || f != 3 {
+		t.Errorf("TestInject - expect (dir, file) count to be (2, 3), got (%d, %d)", d, f)
+	}
+	inf.Print(opts)
+	expected := `root
+┣━ a
+┣━ b
+┃ ┗━ and 3 more
+┗━ c
+  ┗━ d
+`
+	if !out.equal(expected) {
+		t.Errorf("inject:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name:  "root",
+		files: []*file{{name: "a", size: 5}, {name: "b c", files: []*file{{name: "d", size: 2}}}},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, CSV: true}
+	CSVHeader(opts, out)
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `path,depth,type,size,mode,uid,gid,mtime,inode
+root,0,dir,7,----------,0,0,0001-01-01T00:00:00Z,0
+root/a,1,file,5,----------,0,0,0001-01-01T00:00:00Z,0
+root/b c,1,dir,2,----------,0,0,0001-01-01T00:00:00Z,0
+root/b c/d,2,file,2,----------,0,0,0001-01-01T00:00:00Z,0
+`
+	if !out.equal(expected) {
+		t.Errorf("csv:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name:  "root",
+		files: []*file{{name: "a", size: 5}, {name: "b", files: []*file{{name: "c", size: 2}}}},
+	}
+	fs.clean().addFile(root.name, root)
+	tmpl := template.Must(template.New("test").Parse("{{.Depth}} {{.Path}} {{.Size}} {{.IsDir}}\n"))
+	opts := &Options{Fs: fs, OutFile: out, Template: tmpl}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := `0 root 7 true
+1 root/a 5 false
+1 root/b 2 true
+2 root/b/c 2 false
+`
+	if !out.equal(expected) {
+		t.Errorf("template:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name:  "root",
+		files: []*file{{name: "a", size: 5}, {name: "b", files: []*file{{name: "c", size: 2}}}},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, JSON: true}
+	PrintHeader(opts, "")
+	inf := New(root.name)
+	d, f := inf.Visit(opts)
+	inf.Print(opts)
+	PrintFooter(opts, &Summary{Dirs: d, Files: f, Bytes: NodeSize(inf)})
+	var res []map[string]interface{}
+	if err := json.Unmarshal([]byte(out.str), &res); err != nil {
+		t.Fatalf("json: %v\n%s", err, out.str)
+	}
+	if len(res) != 2 || res[0]["name"] != "root" || res[1]["type"] != "report" {
+		t.Fatalf("json: unexpected output\n%s", out.str)
+	}
+	if contents := res[0]["contents"].([]interface{}); len(contents) != 2 {
+		t.Errorf("json: expected 2 children\n%s", out.str)
+	}
+	if res[1]["files"] != 2.0 || res[1]["size"] != 7.0 {
+		t.Errorf("json: wrong report\n%s", out.str)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a.go"},
+			{name: ".b"},
+			{name: "c~"},
+			{name: "d", files: []*file{{name: "e", files: []*file{{name: "f.go"}}}}},
+		},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, DeepLevel: 2, Pattern: `\.go$`}
+	data := []struct {
+		path string
+		why  string
+	}{
+		{"root/a.go", ""},
+		{"root/.b", "dotfile default (use -a)"},
+		{"root/c~", "backup file (*~)"},
+		{"root/d", ""},
+		{"root/d/e/f.go", "depth cutoff (-L 2)"},
+	}
+	for _, test := range data {
+		why, err := Explain(opts, "root", test.path)
+		if err != nil || why != test.why {
+			t.Errorf("explain %s: got %q (%v) expected %q", test.path, why, err, test.why)
+		}
+	}
+	opts.SkipSuffixes = []string{}
+	if why, _ := Explain(opts, "root", "root/c~"); why != "not matching pattern (-P \\.go$)" {
+		t.Errorf("explain root/c~ without skipping: got %q", why)
+	}
+	opts.Pattern = ""
+	opts.IPattern = `^a`
+	if why, _ := Explain(opts, "root", "root/a.go"); why != "matching ignore pattern (-I ^a)" {
+		t.Errorf("explain root/a.go: got %q", why)
+	}
+	if _, err := Explain(opts, "root", "other/a.go"); err == nil {
+		t.Errorf("explain other/a.go: expected error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	defer out.clear()
+	rootA := &file{name: "a", files: []*file{{name: "b", size: 2}, {name: "c", files: []*file{{name: "d", size: 3}}}}}
+	rootE := &file{name: "e", files: []*file{{name: "f", size: 4}}}
+	fs.clean().addFile(rootA.name, rootA).addFile(rootE.name, rootE)
+	opts := &Options{Fs: fs, OutFile: out}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"a", "e"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != (Summary{Dirs: 1, Files: 3, Bytes: 9}) {
+		t.Errorf("run: wrong summary %+v", sum)
+	}
+	expected := `a
+┣━ b
+┗━ c
+  ┗━ d
+e
+┗━ f
+
+1 directories, 3 files
+`
+	if !out.equal(expected) {
+		t.Errorf("run:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestReport(t *testing.T) {
+	defer out.clear()
+	rootA := &file{name: "a", files: []*file{{name: "b", size: 2}, {name: "c", files: []*file{{name: "d", size: 3}}}}}
+	rootE := &file{name: "e", size: 4}
+	fs.clean().addFile(rootA.name, rootA).addFile(rootE.name, rootE)
+	opts := &Options{Fs: fs, OutFile: out}
+	infA, infE := New("a"), New("e")
+	infA.Visit(opts)
+	infE.Visit(opts)
+	sum := Report(opts, infA, infE)
+	if sum != (Summary{Dirs: 1, Files: 3, Bytes: 9}) {
+		t.Errorf("report: wrong summary %+v", sum)
+	}
+	PrintReport(opts, &sum)
+	if expected := "\n1 directories, 3 files\n"; !out.equal(expected) {
+		t.Errorf("report:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+	out.clear()
+	opts.NoReport = true
+	PrintReport(opts, &sum)
+	if out.str != "" {
+		t.Errorf("report: expected nothing for NoReport, got %q", out.str)
+	}
+}
+
+func TestRunEncoding(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "a", files: []*file{{name: "é"}}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, NoReport: true, NoIndent: true,
+		Encoding: "utf-16le"}
+	if _, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\xff\xfea\x00\n\x00\xe9\x00\n\x00"
+	if !out.equal(expected) {
+		t.Errorf("utf-16le:\ngot:\n%q\nexpected:\n%q", out.str, expected)
+	}
+	if opts.OutFile != out {
+		t.Errorf("utf-16le: OutFile wasn't restored")
+	}
+
+	opts.Encoding = "latin1"
+	if _, err := Run(context.Background(), RunConfig{Options: opts}); err == nil {
+		t.Errorf("expected an error for an unknown encoding")
+	}
+}
+
+func TestRootName(t *testing.T) {
+	defer out.clear()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &file{name: "a b", files: []*file{{name: "c d"}}}
+	fs.clean().addFile(root.name, root).addFile(wd, root)
+
+	opts := &Options{Fs: fs, OutFile: out, QuoteRoot: true}
+	inf := New("a b")
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := "\"a b\"\n┗━ c d\n"
+	if !out.equal(expected) {
+		t.Errorf("quote root:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+
+	out.clear()
+	opts = &Options{Fs: fs, OutFile: out, DotRoot: true}
+	inf = New(wd)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected = ".\n┗━ c d\n"
+	if !out.equal(expected) {
+		t.Errorf("dot root:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{{name: "a", size: 5}, {name: "b"}}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, JSON: true}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			if node.Path() == "root/a" {
+				node.Annotate("owner", "team-a")
+			}
+			return next(opts, node)
+		}
+	}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	var res map[string]interface{}
+	if err := json.Unmarshal([]byte(out.str), &res); err != nil {
+		t.Fatalf("json: %v\n%s", err, out.str)
+	}
+	contents := res["contents"].([]interface{})
+	extra, _ := contents[0].(map[string]interface{})["extra"].(map[string]interface{})
+	if extra["owner"] != "team-a" {
+		t.Errorf("annotate: expected the extra owner\n%s", out.str)
+	}
+	if _, ok := contents[1].(map[string]interface{})["extra"]; ok {
+		t.Errorf("annotate: unexpected extra\n%s", out.str)
+	}
+}
+
+func TestVisitContext(t *testing.T) {
+	root := &file{name: "root", files: []*file{{name: "a"}, {name: "b", files: []*file{{name: "c"}}}}}
+	fs.clean().addFile(root.name, root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	inf := New(root.name)
+	if d, f := inf.VisitContext(ctx, &Options{Fs: fs, OutFile: out}); d != 0 || f != 0 {
+		t.Errorf("cancelled: expected no dirs/files, got (%d, %d)", d, f)
+	}
+	if inf.err != context.Canceled {
+		t.Errorf("cancelled: expected the ctx error, got %v", inf.err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	opts := &Options{Fs: fs, OutFile: out}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			if node.Path() == "root/b" {
+				cancel()
+			}
+			return next(opts, node)
+		}
+	}
+	inf = New(root.name)
+	inf.VisitContext(ctx, opts)
+	if Report(opts, inf).Errors == 0 {
+		t.Errorf("cancel: expected a dir with the ctx error")
+	}
+}
+
+// errFs fails to read the dirs. in errs
+type errFs struct {
+	Fs
+	errs map[string]bool
+}
+
+func (efs errFs) ReadDir(path string) ([]string, error) {
+	if efs.errs[path] {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+	}
+	return efs.Fs.ReadDir(path)
+}
+
+// statErrFs fails the Stat of the paths in errs
+type statErrFs struct {
+	Fs
+	errs map[string]bool
+}
+
+func (efs statErrFs) Stat(path string) (os.FileInfo, error) {
+	if efs.errs[path] {
+		return nil, &os.PathError{Op: "lstat", Path: path, Err: os.ErrPermission}
+	}
+	return efs.Fs.Stat(path)
+}
+
+// chunkFs is a DirFs, the reads fail after the names in fail
+type chunkFs struct {
+	Fs
+	reads int
+	fail  int
+}
+
+type chunkDir struct {
+	fs    *chunkFs
+	names []string
+}
+
+func (cfs *chunkFs) OpenDir(path string) (DirReader, error) {
+	names, err := cfs.ReadDir(path)
+	return &chunkDir{cfs, names}, err
+}
+
+func (d *chunkDir) Readdirnames(n int) ([]string, error) {
+	d.fs.reads++
+	if d.fs.fail > 0 && len(d.names) <= d.fs.fail {
+		return nil, &os.PathError{Op: "readdirent", Path: "x", Err: os.ErrInvalid}
+	}
+	if len(d.names) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.names) {
+		n = len(d.names)
+	}
+	names := d.names[:n]
+	d.names = d.names[n:]
+	return names, nil
+}
+func (d *chunkDir) Close() error { return nil }
+
+func TestDirFs(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root"}
+	for i := 0; i < readDirChunk+10; i++ {
+		root.files = append(root.files, &file{name: fmt.Sprint(i)})
+	}
+	fs.clean().addFile(root.name, root)
+	cfs := &chunkFs{Fs: fs}
+	opts := &Options{Fs: cfs, OutFile: out}
+	inf := New(root.name)
+	if _, f := inf.Visit(opts); f != readDirChunk+10 || cfs.reads != 2 {
+		t.Errorf("chunks: expected %d files in 2 reads, got %d in %d", readDirChunk+10, f, cfs.reads)
+	}
+
+	cfs = &chunkFs{Fs: fs, fail: 10}
+	opts = &Options{Fs: cfs, OutFile: out}
+	inf = New(root.name)
+	if _, f := inf.Visit(opts); f != readDirChunk || inf.err == nil {
+		t.Errorf("chunks: expected %d files and an error, got %d (%v)", readDirChunk, f, inf.err)
+	}
+}
+
+func TestIgnoreErrors(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "proc", files: []*file{{name: "1", files: []*file{{name: "x"}}}}},
+		{name: "home", files: []*file{{name: "y"}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	efs := errFs{fs, map[string]bool{"root/proc/1": true, "root/home": true}}
+	opts := &Options{Fs: efs, OutFile: out, IgnoreErrors: []string{"root/proc"}}
+	inf := New(root.name)
+	inf.Visit(opts)
+	if n := Report(opts, inf).Errors; n != 1 {
+		t.Errorf("ignore-errors: expected 1 error, got %d", n)
+	}
+	if inf.nodes[0].err == nil && inf.nodes[1].err == nil {
+		t.Errorf("ignore-errors: expected the error for root/home")
+	}
+
+	// The streamed entries don't have the ignored errors either
+	out.clear()
+	sfs := statErrFs{fs, map[string]bool{"root/proc/1": true, "root/home": true}}
+	opts = &Options{Fs: sfs, OutFile: out, NDJSON: true,
+		IgnoreErrors: []string{"root/proc"}}
+	New(root.name).Visit(opts)
+	if strings.Contains(out.str, "root/proc/1") ||
+		!strings.Contains(out.str, "root/home") {
+		t.Errorf("ignore-errors ndjson: got:\n%s", out.str)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "x"}}},
+		{name: "b", files: []*file{{name: "y"}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	ebuf := &Out{}
+	efs := errFs{fs, map[string]bool{"root/b": true}}
+	opts := &Options{Fs: efs, OutFile: out, Errors: ebuf}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	errs := inf.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("errors: expected 1 error, got %v", errs)
+	}
+	if nerr, ok := errs[0].(*NodeError); !ok || nerr.Path != "root/b" {
+		t.Errorf("errors: expected error for root/b, got %v", errs[0])
+	}
+	if !strings.HasPrefix(ebuf.str, "root/b [") {
+		t.Errorf("errors: expected error line, got %q", ebuf.str)
+	}
+	if strings.Contains(out.str, "root/b") {
+		t.Errorf("errors: expected no error in the tree, got %q", out.str)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	root := &file{name: "root", files: []*file{{name: "a"}, {name: "b", files: []*file{{name: "c"}}}}}
+	fs.clean().addFile(root.name, root)
+	var calls int
+	var path string
+	opts := &Options{Fs: fs, OutFile: out}
+	opts.Progress = func(dirs, files int64, cur string) {
+		calls++
+		path = cur
+	}
+	inf := New(root.name)
+	inf.Visit(opts)
+	if calls != 1 || path != "root" {
+		t.Errorf("progress: expected 1 call for root, got %d (%s)", calls, path)
+	}
+	if opts.prog.dirs != 2 || opts.prog.files != 2 {
+		t.Errorf("progress: expected (2, 2) got (%d, %d)", opts.prog.dirs, opts.prog.files)
+	}
+}
+
+func TestOwner(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", stat: &syscall.Stat_t{Uid: 4201, Gid: 4301}},
+		{name: "b", stat: &syscall.Stat_t{Uid: 4202, Gid: 4301}},
+		{name: "c", stat: &syscall.Stat_t{Uid: 4201, Gid: 4302}},
+	}}
+	fs.clean().addFile(root.name, root)
+	for _, tc := range []struct {
+		user, group string
+		expected    string
+	}{
+		{"4201", "", "root\n┣━ a\n┗━ c\n"},
+		{"", "4301", "root\n┣━ a\n┗━ b\n"},
+		{"4201", "4301", "root\n┗━ a\n"},
+		{"4203", "", "root\n"},
+	} {
+		out.clear()
+		opts := &Options{Fs: fs, OutFile: out, User: tc.user, Group: tc.group}
+		inf := New(root.name)
+		inf.Visit(opts)
+		inf.Print(opts)
+		if !out.equal(tc.expected) {
+			t.Errorf("%q/%q:\ngot:\n%+v\nexpected:\n%+v", tc.user, tc.group, out.str, tc.expected)
+		}
+	}
+}
+
+type panicFs struct {
+	Fs
+	path string
+}
+
+func (pfs panicFs) ReadDir(path string) ([]string, error) {
+	if path == pfs.path {
+		panic("broken fs")
+	}
+	return pfs.Fs.ReadDir(path)
+}
+
+func TestPanic(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b"}, {name: "c"}}},
+		{name: "d", files: []*file{{name: "e"}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	expected := "root\nroot/a [broken fs]\n┗━ d\n  ┗━ e\n"
+	for _, num := range []int{0, 1} {
+		out.clear()
+		opts := &Options{Fs: panicFs{fs, "root/a"}, OutFile: out, Concurrency: num}
+		inf := New(root.name)
+		inf.Visit(opts)
+		inf.Print(opts)
+		if errs := inf.Errors(); len(errs) != 1 || errs[0].(*NodeError).Path != "root/a" {
+			t.Errorf("concurrency %d: expected the panic for root/a, got %v", num, errs)
+		}
+		if !out.equal(expected) {
+			t.Errorf("concurrency %d:\ngot:\n%+v\nexpected:\n%+v", num, out.str, expected)
+		}
+	}
+}
+
+type deniedFs struct {
+	Fs
+}
+
+func (dfs deniedFs) ReadDir(path string) ([]string, error) {
+	if strings.HasPrefix(path, "root/") {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+	}
+	return dfs.Fs.ReadDir(path)
+}
+
+func TestDenied(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b"}}},
+		{name: "c", files: []*file{}},
+		{name: "d"},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: deniedFs{fs}, OutFile: out}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Errors != 2 || sum.Denied != 2 {
+		t.Errorf("expected 2 denied errors, got %+v", sum)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b"}, {name: "c"}}},
+		{name: "d", files: []*file{{name: "e", files: []*file{{name: "f"}}}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	var expected string
+	for _, num := range []int{0, 1, 2} {
+		out.clear()
+		opts := &Options{Fs: fs, OutFile: out, Concurrency: num}
+		inf := New(root.name)
+		if d, f := inf.Visit(opts); d != 3 || f != 3 {
+			t.Errorf("concurrency %d: expected (3, 3), got (%d, %d)", num, d, f)
+		}
+		inf.Print(opts)
+		if num == 0 {
+			expected = out.str
+		} else if !out.equal(expected) {
+			t.Errorf("concurrency %d:\ngot:\n%+v\nexpected:\n%+v", num, out.str, expected)
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b"}, {name: "c"}}},
+		{name: "d", files: []*file{{name: "e", files: []*file{{name: "f"}}}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	out.clear()
+	opts := &Options{Fs: fs, OutFile: out, Concurrency: 1}
+	inf := New(root.name)
+	inf.Visit(opts)
+	inf.Print(opts)
+	expected := out.str
+
+	pool := NewPool(1)
+	infs := make([]*Node, 4)
+	var wg sync.WaitGroup
+	for i := range infs {
+		infs[i] = New(root.name)
+		wg.Add(1)
+		go func(inf *Node) {
+			defer wg.Done()
+			inf.Visit(&Options{Fs: fs, OutFile: out, Pool: pool})
+		}(infs[i])
+	}
+	wg.Wait()
+	for i, inf := range infs {
+		out.clear()
+		inf.Print(&Options{Fs: fs, OutFile: out})
+		if !out.equal(expected) {
+			t.Errorf("pool %d:\ngot:\n%+v\nexpected:\n%+v", i, out.str, expected)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{{name: "a"}, {name: "b"}, {name: "c"}}}
+	fs.clean().addFile(root.name, root)
+	// 4 stats and a readdir, the first is at once
+	for _, opts := range []*Options{
+		{Fs: fs, OutFile: out, Throttle: 100},
+		{Fs: fs, OutFile: out, Pool: NewPool(2).Throttle(100)},
+	} {
+		start := time.Now()
+		if d, f := New(root.name).Visit(opts); d != 0 || f != 3 {
+			t.Errorf("expected (0, 3), got (%d, %d)", d, f)
+		}
+		if took := time.Since(start); took < 40*time.Millisecond {
+			t.Errorf("expected at least 40ms, took %v", took)
+		}
+	}
+}
+
+func TestCompat(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "a", files: []*file{
+		{name: "b~"},
+		{name: "c", files: []*file{{name: "e", files: []*file{{name: "f"}}}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, JoinSingle: true, DeepLevel: -1}
+	if err := opts.SetCompat("bsd"); err == nil {
+		t.Errorf("expected an error for bsd")
+	}
+	if err := opts.SetCompat("gnu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `a
+|-- b~
+` + "`" + `-- c
+    ` + "`" + `-- e
+        ` + "`" + `-- f
+
+2 directories, 2 files
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestStream(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b", size: 2}, {name: "c", size: 3}}},
+		{name: "d", size: 4},
+		{name: "e", files: []*file{{name: "f", files: []*file{{name: "g", size: 5}}}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out}
+	inf := New(root.name)
+	vd, vf := inf.Visit(opts)
+	inf.Print(opts)
+	expected := out.str
+
+	out.clear()
+	inf = New(root.name)
+	if d, f := inf.Stream(opts); d != vd || f != vf {
+		t.Errorf("stream: expected (%d, %d), got (%d, %d)", vd, vf, d, f)
+	}
+	if !out.equal(expected) {
+		t.Errorf("stream:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+
+	out.clear()
+	var entries []string
+	var last Summary
+	opts = &Options{Fs: fs, OutFile: out, Stream: true}
+	opts.StreamTotals = func(node *Node, totals Summary) {
+		entries = append(entries, node.Name())
+		last = totals
+	}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root", "root/a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != (Summary{Dirs: 3, Files: 6, Bytes: 19}) || last != sum {
+		t.Errorf("stream: wrong summary %+v (last totals %+v)", sum, last)
+	}
+	if got := strings.Join(entries, " "); got != "root a b c d e f g a b c" {
+		t.Errorf("stream: wrong entries for the totals %s", got)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root := &file{name: "root", files: []*file{
+		{name: "c", size: 1},
+		{name: "a", files: []*file{{name: "b", size: 2}}},
+		{name: "d", files: []*file{{name: "e", size: 3}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out}
+	inf := New(root.name)
+	inf.Visit(opts)
+
+	var paths []string
+	err := inf.WalkSorted(opts, func(n *Node) error {
+		paths = append(paths, fmt.Sprintf("%d:%s", n.Depth(), n.Path()))
+		if n.Name() == "d" {
+			return SkipNode
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "0:root 1:root/a 2:root/a/b 1:root/c 1:root/d"
+	if got := strings.Join(paths, " "); got != expected {
+		t.Errorf("walk: got %s expected %s", got, expected)
+	}
+
+	stop := fmt.Errorf("stop")
+	var num int
+	err = inf.Walk(func(n *Node) error {
+		num++
+		if num == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || num != 2 {
+		t.Errorf("walk: expected to stop after 2 nodes, got %d %v", num, err)
+	}
+}
+
+func TestSubtree(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "c", size: 1},
+		{name: "a", files: []*file{{name: "b", size: 2}, {name: "x", size: 4}}},
+		{name: "d", files: []*file{{name: "e", size: 3}}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, ByteSize: true}
+	inf := New(root.name)
+	inf.Visit(opts)
+
+	sub := inf.Subtree("a")
+	if sub == nil || sub.Depth() != 0 || inf.Subtree("root/a").Path() != "root/a" {
+		t.Fatalf("subtree: expected root/a as a root, got %v", sub)
+	}
+	if inf.Subtree("a/y") != nil {
+		t.Errorf("subtree: expected nil for a missing path")
+	}
+	pruned := sub.Prune(func(n *Node) bool { return n.Name() == "x" })
+	pruned.Print(opts)
+	expected := `          2 root/a
+          2 ┗━ b
+`
+	if !out.equal(expected) {
+		t.Errorf("prune:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+	if size, _ := DirRecursiveSize(sub); size != 6 || len(inf.Subtree("a").Children()) != 2 {
+		t.Errorf("prune: expected the original tree to be unchanged")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	root := &file{name: "root", files: []*file{{name: "a", files: []*file{{name: "b"}}}}}
+	fs.clean().addFile(root.name, root)
+	efs := errFs{fs, map[string]bool{"root/a": true}}
+	opts := &Options{Fs: efs, OutFile: out}
+	opts.Inject = func(dir *Node) Nodes {
+		if dir.Depth() != 0 {
+			return nil
+		}
+		return Nodes{NewVirtual(VirtualInfo("v", 1, 0644, time.Time{}))}
+	}
+	inf := New(root.name)
+	inf.Visit(opts)
+	children := inf.SortedChildren(opts)
+	if inf.Path() != "root" || inf.Depth() != 0 || inf.Err() != nil || len(children) != 2 {
+		t.Fatalf("accessors: unexpected root %s %d %v %d", inf.Path(), inf.Depth(), inf.Err(), len(children))
+	}
+	a, v := children[0], children[1]
+	if a.Path() != "root/a" || a.Depth() != 1 || a.Err() == nil || a.IsVirtual() {
+		t.Errorf("accessors: unexpected a %s %d %v", a.Path(), a.Depth(), a.Err())
+	}
+	if v.Path() != "root/v" || !v.IsVirtual() || len(v.Children()) != 0 {
+		t.Errorf("accessors: unexpected v %s %v", v.Path(), v.IsVirtual())
+	}
+}
+
+// cancelFs cancels the visit when the dir. is read
+type cancelFs struct {
+	Fs
+	dir    string
+	cancel context.CancelFunc
+}
+
+func (cfs cancelFs) ReadDir(path string) ([]string, error) {
+	if path == cfs.dir {
+		cfs.cancel()
+	}
+	return cfs.Fs.ReadDir(path)
+}
+
+func TestPartial(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", files: []*file{{name: "b"}, {name: "e"}}},
+		{name: "c", files: []*file{{name: "f"}}},
+		{name: "d"},
+	}}
+	fs.clean().addFile(root.name, root)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := &Options{Fs: cancelFs{fs, "root/c", cancel}, OutFile: out,
+		Concurrency: 1}
+	conf := RunConfig{Options: opts, Paths: []string{"root"}}
+	if _, err := Run(ctx, conf); err != context.Canceled {
+		t.Errorf("expected the ctx error, got %v", err)
+	}
+
+	out.clear()
+	ctx, cancel = context.WithCancel(context.Background())
+	opts.Fs = cancelFs{fs, "root/c", cancel}
+	conf.Partial = true
+	sum, err := Run(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `root [interrupted]
+┣━ a
+┃ ┣━ b
+┃ ┗━ e
+┗━ c [interrupted]
+
+2 directories, 2 files
+(interrupted, the tree is incomplete)
+`
+	if !sum.Incomplete || !out.equal(expected) {
+		t.Errorf("got %+v:\n%+v\nexpected:\n%+v", sum, out.str, expected)
+	}
+}
+
+func TestDirsOnlySize(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a", size: 100},
+		{name: "b", files: []*file{
+			{name: "c", size: 1000},
+			{name: "d", files: []*file{{name: "e", size: 50}}},
+		}},
+	}}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, DirsOnly: true, ByteSize: true}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `       1150 root
+       1050 ┗━ b
+         50   ┗━ d
+
+2 directories, 1,150 size (including 3 hidden files)
+`
+	if sum.Hidden != 3 || !out.equal(expected) {
+		t.Errorf("got %+v:\n%+v\nexpected:\n%+v", sum, out.str, expected)
+	}
+}
+
+func TestDepthReport(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root", files: []*file{
+		{name: "a_long_name"},
+		{name: "b", files: []*file{
+			{name: "c", files: []*file{{name: "d"}}},
+		}},
+	}}
+	fs.clean().addFile(root.name, root)
+	for _, stream := range []bool{false, true} {
+		opts := &Options{Fs: fs, OutFile: out, DepthReport: true, Stream: stream}
+		sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum.MaxDepth != 3 || sum.LongestPath != "root/a_long_name" {
+			t.Errorf("stream %v: got %+v", stream, sum)
+		}
+		if !strings.HasSuffix(out.str, "max depth 3, longest path (16 bytes): root/a_long_name\n") {
+			t.Errorf("stream %v: got:\n%+v", stream, out.str)
+		}
+		out.clear()
+	}
+}
+
+func TestNoSortOrder(t *testing.T) {
+	defer out.clear()
+	root := &file{name: "root"}
+	var names []string
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("f%02d", (i*37)%100) // Not in name order
+		root.files = append(root.files, &file{name: name, size: int64(i)})
+		names = append(names, name)
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, NoSort: true}
+	for run := 0; run < 5; run++ {
+		inf := New(root.name)
+		inf.Visit(opts)
+		var got []string
+		for _, nnode := range inf.sortedNodes(opts) {
+			got = append(got, nnode.Name())
+		}
+		if strings.Join(got, ",") != strings.Join(names, ",") {
+			t.Fatalf("TestNoSortOrder - expect the ReadDir order, got: %v", got)
+		}
+	}
+}
+
+func TestHideEmptySize(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a/b", []byte("hello\n"), 0644, testTime).
+		AddDir("root/c/d", 0755, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"hide-empty", &Options{UnitSize: true, JoinSingle: true,
+			HideEmptySize: true, EmptySizeText: "-"}, `
+   6 root
+   6 ┣━ a/b
+   - ┗━ c/d
+`, 0, 0},
+	})
+}
+
+func TestJoinCounts(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a/b/c", []byte("hello\n"), 0644, testTime).
+		AddFile("root/a/b/d", []byte("world\n"), 0644, testTime).
+		AddFile("root/e", nil, 0644, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"join-counts", &Options{JoinSingle: true, JoinCounts: true}, `
+root
+┣━ a/b [2 files, 12]
+┃ ┣━ c
+┃ ┗━ d
+┗━ e
+`, 0, 0},
+	})
+}
+
+func TestPathSep(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a/b/c", nil, 0644, testTime).
+		AddFile("root/d", nil, 0644, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"join", &Options{JoinSingle: true, PathSep: ":"}, `
+root
+┣━ a:b:c
+┗━ d
+`, 0, 0},
+		{"full-path", &Options{FullPath: true, PathSep: ":"}, `
+root
+┣━ root:a
+┃ ┗━ root:a:b
+┃   ┗━ root:a:b:c
+┗━ root:d
+`, 0, 0},
+	})
+}
+
+func TestMtimeRollup(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a/b/c", nil, 0644, testTime.Add(48*time.Hour)).
+		AddFile("root/d/e", nil, 0644, testTime.Add(24*time.Hour)).
+		AddDir("root/a/b", 0755, testTime).
+		AddDir("root/a", 0755, testTime).
+		AddDir("root/d", 0755, testTime).
+		AddDir("root", 0755, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"mtime-rollup", &Options{LastMod: true, ModSort: true,
+			MtimeRollup: true}, `
+2020-01-04 03:04 root
+2020-01-03 03:04 ┣━ d
+2020-01-03 03:04 ┃ ┗━ e
+2020-01-04 03:04 ┗━ a
+2020-01-04 03:04   ┗━ b
+2020-01-04 03:04     ┗━ c
+`, 0, 0},
+	})
+}
+
+func TestLinkTargets(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a", []byte("hello\n"), 0644, testTime).
+		AddSymlink("root/b", "missing", testTime).
+		AddSymlink("root/c/d", "../a", testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"link-targets", &Options{LinkTargets: true, TimeFormat: "2006-01-02"}, `
+root
+┣━ a
+┣━ b -> missing [broken]
+┗━ c
+  ┗━ d -> ../a [-rw-r--r-- 6 2020-01-02]
+`, 0, 0},
+	})
+}
+
+func TestHTML(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a&b", []byte("hello\n"), 0644, testTime).
+		AddFile("root/c d/e", nil, 0644, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"html", &Options{HTML: true, ByteSize: true}, `
+<ul class="tree">
+  <li><details open><summary><span class="props">          6</span> <a id="tree-" href="."><span class="dir">root</span></a></summary>
+  <ul>
+    <li><span class="props">          6</span> <a id="tree-a&amp;b" href="a&amp;b">a&amp;b</a></li>
+    <li><details><summary><span class="props">          0</span> <a id="tree-c%20d" href="c%20d"><span class="dir">c d</span></a></summary>
+    <ul>
+      <li><span class="props">          0</span> <a id="tree-c%20d/e" href="c%20d/e">e</a></li>
+    </ul>
+    </details></li>
+  </ul>
+  </details></li>
+</ul>
+`, 0, 0},
+		{"base-href", &Options{HTML: true, BaseHREF: "http://x/", DeepLevel: 1}, `
+<ul class="tree">
+  <li><details open><summary><a id="tree-" href="http://x/"><span class="dir">root</span></a></summary>
+  <ul>
+    <li><a id="tree-a&amp;b" href="http://x/a&amp;b">a&amp;b</a></li>
+    <li><a id="tree-c%20d" href="http://x/c%20d"><span class="dir">c d</span></a></li>
+  </ul>
+  </details></li>
+</ul>
+`, 0, 0},
+	})
+}
+
+func TestIncompleteAnnotation(t *testing.T) {
+	mfs := NewMapFs().AddFile("root/a", nil, 0644, testTime)
+
+	// Only the dirs. the ctx cut short are interrupted
+	opts := &Options{}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			node.Annotate("incomplete", "mine")
+			return next(opts, node)
+		}
+	}
+	testMapFs(t, mfs, []treeTest{
+		{"annotation", opts, `
+root
+┗━ a
+`, 0, 0},
+	})
+}
@@ -0,0 +1,344 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/transform"
+)
+
+// Summary is the count of everything in the trees printed, for the report
+// at the end of the output.
+type Summary struct {
+	Dirs   int   `json:"directories"`
+	Files  int   `json:"files"`
+	Bytes  int64 `json:"size"`
+	Errors int   `json:"errors"`
+	// Denied is the errors that are permission denied
+	Denied int `json:"denied,omitempty"`
+	// Violations of Options.Policy
+	Violations int `json:"violations,omitempty"`
+	// Saved is the bytes not on disk, for Options.Savings
+	Saved int64 `json:"saved,omitempty"`
+	// Estimate is the estimated totals, for Options.Sample
+	Estimate *Estimate `json:"estimate,omitempty"`
+	// Hidden is the files not shown for Options.DirsOnly, their sizes are
+	// in the dirs.
+	Hidden int `json:"hidden,omitempty"`
+	// Ages is the files in each bucket, for Options.AgeBuckets
+	Ages *AgeCounts `json:"ages,omitempty"`
+	// MaxDepth and LongestPath (in bytes) are for Options.DepthReport
+	MaxDepth    int    `json:"max_depth,omitempty"`
+	LongestPath string `json:"longest_path,omitempty"`
+	// Incomplete is set if the visit was stopped, for RunConfig.Partial
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// incompleteText ends the text report of an Incomplete tree
+const incompleteText = "\n(interrupted, the tree is incomplete)"
+
+// NeedsPrivileges returns if entries couldn't be read because of their
+// permissions and the process isn't root, so the tree is partial.
+func (sum *Summary) NeedsPrivileges() bool {
+	return sum.Denied > 0 && os.Geteuid() != 0
+}
+
+// reportText returns the text report, with the locale's number formatting.
+func reportText(opts *Options, sum *Summary) string {
+	if opts.Compat == "gnu" {
+		footer := gnuReportText(opts, sum)
+		if sum.Incomplete {
+			footer += incompleteText
+		}
+		return footer
+	}
+	p := message.NewPrinter(language.Make(os.Getenv("LANG")))
+
+	footer := p.Sprintf("\n%d directories", sum.Dirs)
+	if !opts.DirsOnly {
+		footer += p.Sprintf(", %d files", sum.Files)
+	}
+	showSize := opts.UnitSize || opts.ByteSize
+	if showSize {
+		if opts.UnitSize {
+			footer += fmt.Sprintf(", %s size", FormatSize(opts, sum.Bytes))
+		} else {
+			footer += p.Sprintf(", %d size", sum.Bytes)
+		}
+		if opts.DirsOnly && sum.Hidden > 0 {
+			footer += p.Sprintf(" (including %d hidden files)", sum.Hidden)
+		}
+	}
+	if opts.Policy != nil {
+		footer += p.Sprintf(", %d permission violations", sum.Violations)
+	}
+	if opts.Savings {
+		if opts.UnitSize {
+			footer += fmt.Sprintf(", %s saved by sparse/compressed files",
+				strings.TrimSpace(FormatSize(opts, sum.Saved)))
+		} else {
+			footer += p.Sprintf(", %d saved by sparse/compressed files", sum.Saved)
+		}
+	}
+	if est := sum.Estimate; est != nil {
+		footer += p.Sprintf("\nestimated %.0f (±%.0f) directories", est.Dirs, est.DirsErr)
+		if !opts.DirsOnly {
+			footer += p.Sprintf(", %.0f (±%.0f) files", est.Files, est.FilesErr)
+		}
+		if opts.UnitSize {
+			footer += fmt.Sprintf(", %s (±%s) size",
+				FormatSize(opts, int64(est.Bytes)), FormatSize(opts, int64(est.BytesErr)))
+		} else if showSize {
+			footer += p.Sprintf(", %.0f (±%.0f) size", est.Bytes, est.BytesErr)
+		}
+	}
+	if opts.AgeBuckets != nil {
+		footer += ageReportText(opts, p, sum)
+	}
+	if opts.DepthReport {
+		footer += p.Sprintf("\nmax depth %d, longest path (%d bytes): %s",
+			sum.MaxDepth, len(sum.LongestPath), sum.LongestPath)
+	}
+	if sum.Incomplete {
+		footer += incompleteText
+	}
+	return footer
+}
+
+// PrintReport writes the text report for the sum, like "2 directories, 3
+// files", unless Options.NoReport. For the other output formats it's part of
+// PrintFooter.
+func PrintReport(opts *Options, sum *Summary) {
+	if opts.NoReport {
+		return
+	}
+	fmt.Fprintln(opts.OutFile, reportText(opts, sum))
+}
+
+// addDepth updates the MaxDepth and LongestPath with the node.
+func (sum *Summary) addDepth(node *Node) {
+	if node.depth > sum.MaxDepth {
+		sum.MaxDepth = node.depth
+	}
+	if len(node.path) > len(sum.LongestPath) {
+		sum.LongestPath = node.path
+	}
+}
+
+// outputFormats are the names for SetOutputFormat
+var outputFormats = []string{
+	"text", "json", "ndjson", "xml", "html", "csv", "tsv", "md",
+}
+
+// outputExts map file extensions to the output format
+var outputExts = map[string]string{
+	".txt":    "text",
+	".json":   "json",
+	".ndjson": "ndjson",
+	".jsonl":  "ndjson",
+	".xml":    "xml",
+	".html":   "html",
+	".htm":    "html",
+	".csv":    "csv",
+	".tsv":    "tsv",
+	".md":     "md",
+}
+
+// OutputFormatForFile returns the output format for the extension of the
+// file name, or "" if it's not known.
+func OutputFormatForFile(name string) string {
+	return outputExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// SetOutputFormat sets the output options for the format name, one of:
+// text, json, ndjson, xml, html, csv, tsv, md.
+func (opts *Options) SetOutputFormat(name string) error {
+	var found bool
+	for _, oformat := range outputFormats {
+		if name == oformat {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("output format '%s' not valid, should be one of: %s",
+			name, strings.Join(outputFormats, ","))
+	}
+
+	opts.HTML = name == "html"
+	opts.NDJSON = name == "ndjson"
+	opts.CSV = name == "csv"
+	opts.TSV = name == "tsv"
+	opts.JSON = name == "json"
+	opts.XML = name == "xml"
+	opts.Markdown = name == "md"
+	return nil
+}
+
+// GNUGraphics are the ASCII lines of GNU tree (--charset=ascii).
+var GNUGraphics = Graphics{
+	Branch:   "|-- ",
+	Last:     "`-- ",
+	Vertical: "|   ",
+	Space:    "    ",
+	Cutoff:   "`-- ",
+}
+
+// SetCompat sets the options to print the text output like another tree
+// command, the only one is "gnu" (GNU tree in the C locale). Later changes to
+// the options can still be used, like -a or -L.
+func (opts *Options) SetCompat(name string) error {
+	if name != "gnu" {
+		return fmt.Errorf("compat '%s' not valid, should be: gnu", name)
+	}
+	opts.Compat = name
+	opts.Graphics = &GNUGraphics
+	opts.JoinSingle = false
+	opts.NameSort = true
+	opts.DotRoot = false
+	opts.QuoteRoot = false
+	opts.SkipSuffixes = []string{}
+	opts.TreeIgnore = false
+	if opts.DeepLevel < 0 { // No dynamic levels
+		opts.DeepLevel = 0
+	}
+	return nil
+}
+
+// gnuReportText returns the text report like GNU tree, without the
+// locale's number formatting.
+func gnuReportText(opts *Options, sum *Summary) string {
+	plural := func(num int, one, many string) string {
+		if num == 1 {
+			return fmt.Sprintf("%d %s", num, one)
+		}
+		return fmt.Sprintf("%d %s", num, many)
+	}
+	footer := "\n" + plural(sum.Dirs, "directory", "directories")
+	if !opts.DirsOnly {
+		footer += ", " + plural(sum.Files, "file", "files")
+	}
+	return footer
+}
+
+// outputEncodings are the names for EncodeOutput
+var outputEncodings = []string{"utf-8", "utf-8-bom", "utf-16le"}
+
+// nopWriteCloser is a Writer with a Close that does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// bomWriter writes the UTF-8 BOM before the first write
+type bomWriter struct {
+	io.Writer
+	done bool
+}
+
+func (w *bomWriter) Write(data []byte) (int, error) {
+	if !w.done {
+		w.done = true
+		if _, err := w.Writer.Write([]byte("\uFEFF")); err != nil {
+			return 0, err
+		}
+	}
+	return w.Writer.Write(data)
+}
+
+func (w *bomWriter) Close() error { return nil }
+
+// EncodeOutput returns a Writer that converts the UTF-8 output to w in the
+// encoding, one of: utf-8, utf-8-bom, utf-16le (with a BOM, which legacy
+// Windows tools expect). Close flushes it, but doesn't close w.
+func EncodeOutput(w io.Writer, name string) (io.WriteCloser, error) {
+	switch name {
+	case "", "utf-8":
+		return nopWriteCloser{w}, nil
+	case "utf-8-bom":
+		return &bomWriter{Writer: w}, nil
+	case "utf-16le":
+		enc := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+		return transform.NewWriter(w, enc), nil
+	}
+	return nil, fmt.Errorf("output encoding '%s' not valid, should be one of: %s",
+		name, strings.Join(outputEncodings, ","))
+}
+
+// textOutput returns if the output is the text tree, and not one of the
+// other formats.
+func (opts *Options) textOutput() bool {
+	return !(opts.HTML || opts.NDJSON || opts.CSV || opts.TSV || opts.JSON ||
+		opts.XML || opts.Markdown)
+}
+
+// PrintHeader writes anything the output format needs before the first
+// tree, title is used by formats that have one (HTML).
+func PrintHeader(opts *Options, title string) {
+	switch {
+	case opts.NDJSON:
+	case opts.CSV || opts.TSV:
+		CSVHeader(opts, opts.OutFile)
+	case opts.JSON:
+		fmt.Fprintln(opts.OutFile, "[")
+	case opts.XML:
+		encoding := "UTF-8"
+		if opts.Encoding == "utf-16le" {
+			encoding = "UTF-16"
+		}
+		fmt.Fprintf(opts.OutFile, "<?xml version=\"1.0\" encoding=\"%s\"?>\n", encoding)
+		fmt.Fprintln(opts.OutFile, "<tree>")
+	case opts.HTML:
+		HTMLHeader(opts.OutFile, title)
+	}
+}
+
+// PrintFooter writes anything the output format needs after the last tree,
+// including the report for the sum (if not nil).
+func PrintFooter(opts *Options, sum *Summary) {
+	var report string
+	if sum != nil {
+		report = reportText(opts, sum)
+	}
+	switch {
+	case opts.NDJSON:
+	case opts.CSV || opts.TSV:
+	case opts.JSON:
+		if sum != nil {
+			if opts.printed > 0 {
+				fmt.Fprintln(opts.OutFile, ",")
+			}
+			data, _ := json.Marshal(struct {
+				Type string `json:"type"`
+				*Summary
+			}{"report", sum})
+			fmt.Fprintf(opts.OutFile, "  %s\n", data)
+		} else if opts.printed > 0 {
+			fmt.Fprintln(opts.OutFile)
+		}
+		fmt.Fprintln(opts.OutFile, "]")
+	case opts.XML:
+		if sum != nil {
+			fmt.Fprintf(opts.OutFile, "  <report>\n"+
+				"    <directories>%d</directories>\n"+
+				"    <files>%d</files>\n"+
+				"    <size>%d</size>\n"+
+				"    <errors>%d</errors>\n"+
+				"  </report>\n", sum.Dirs, sum.Files, sum.Bytes, sum.Errors)
+		}
+		fmt.Fprintln(opts.OutFile, "</tree>")
+	case opts.HTML:
+		HTMLFooter(opts.OutFile, report)
+	default:
+		if report != "" {
+			fmt.Fprintln(opts.OutFile, report)
+		}
+	}
+}
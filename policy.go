@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PermPolicy is the expected permissions and owner of the entries, for
+// Options.Policy. Empty fields aren't checked, the modes are octal (0644).
+type PermPolicy struct {
+	FileMode string
+	DirMode  string
+	Owner    string
+	Group    string
+}
+
+// checkMode returns the violation if the mode isn't the expected octal mode.
+func checkMode(mode os.FileMode, expected string) string {
+	if expected == "" {
+		return ""
+	}
+	bits, err := strconv.ParseUint(expected, 8, 32)
+	if err != nil {
+		return fmt.Sprintf("bad expected mode %s", expected)
+	}
+	if have, want := octalMode(mode), fmt.Sprintf("%04o", bits); have != want {
+		return fmt.Sprintf("mode %s != %s", have, want)
+	}
+	return ""
+}
+
+// matchID returns if the id, or its name, is the expected one.
+func matchID(id uint64, name, expected string) bool {
+	return expected == name || expected == strconv.FormatUint(id, 10)
+}
+
+// checkID returns the violation if the id (or its name) isn't expected.
+func checkID(what string, id uint64, name, expected string) string {
+	if expected == "" || matchID(id, name, expected) {
+		return ""
+	}
+	return fmt.Sprintf("%s %s != %s", what, name, expected)
+}
+
+// Violations returns how the node doesn't match the policy.
+func (pol *PermPolicy) Violations(node *Node) []string {
+	var ret []string
+	mode := node.Mode()
+	switch {
+	case mode.IsRegular():
+		if v := checkMode(mode, pol.FileMode); v != "" {
+			ret = append(ret, v)
+		}
+	case mode.IsDir():
+		if v := checkMode(mode, pol.DirMode); v != "" {
+			ret = append(ret, v)
+		}
+	}
+
+	if ok, _, _, uid, gid := getStat(node); ok {
+		if v := checkID("owner", uid, uidConvert(uid, true), pol.Owner); v != "" {
+			ret = append(ret, v)
+		}
+		if v := checkID("group", gid, gidConvert(gid, true), pol.Group); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// checkPolicy sets the policy violations of the node, if any.
+func (node *Node) checkPolicy(opts *Options) {
+	if opts.Policy == nil {
+		return
+	}
+	if v := opts.Policy.Violations(node); len(v) > 0 {
+		node.policy = strings.Join(v, ", ")
+	}
+}
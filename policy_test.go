@@ -0,0 +1,60 @@
+package tree
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a", nil, 0644, testTime).
+		AddFile("root/b", nil, 0600, testTime).
+		AddDir("root/c", 0700, testTime).
+		AddSymlink("root/d", "a", testTime).
+		AddDir("root", 0755, testTime)
+
+	defer out.clear()
+	opts := &Options{Fs: mfs, OutFile: out,
+		Policy: &PermPolicy{FileMode: "644", DirMode: "0755"}}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Violations != 2 {
+		t.Errorf("policy: expected 2 violations, got %d", sum.Violations)
+	}
+	expected := `root
+┣━ a
+┣━ b [mode 0600 != 0644]
+┣━ c [mode 0700 != 0755]
+┗━ d -> a
+
+1 directories, 3 files, 2 permission violations
+`
+	if !out.equal(expected) {
+		t.Errorf("policy:\ngot:\n%+v\nexpected:\n%+v", out.str, expected)
+	}
+}
+
+func TestPolicyAnnotation(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a", nil, 0600, testTime).
+		AddDir("root", 0755, testTime)
+
+	// An annotation with the same name isn't a violation
+	defer out.clear()
+	opts := &Options{Fs: mfs, OutFile: out, NoReport: true}
+	opts.VisitWrapper = func(next VisitFn) VisitFn {
+		return func(opts *Options, node *Node) (int, int, error) {
+			node.Annotate("policy", "mine")
+			return next(opts, node)
+		}
+	}
+	sum, err := Run(context.Background(), RunConfig{Options: opts, Paths: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Violations != 0 || !out.equal("root\n┗━ a\n") {
+		t.Errorf("got %d violations:\n%+v", sum.Violations, out.str)
+	}
+}
@@ -0,0 +1,90 @@
+package tree
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// Pool bounds the goroutines visiting dirs. for many Options, so a process
+// visiting many trees at once has one limit instead of one per tree.
+type Pool struct {
+	sem      *semaphore.Weighted
+	weight   int64
+	throttle *throttle
+}
+
+// NewPool returns a Pool for visiting n dirs. at once, n < 1 is the default
+// (like Options.Concurrency).
+func NewPool(n int) *Pool {
+	weight := (&Options{Concurrency: n}).semWeight()
+	return &Pool{sem: semaphore.NewWeighted(weight), weight: weight}
+}
+
+// Throttle limits the stat/readdir calls of all the Options using the Pool to
+// ops per second, instead of their Options.Throttle. It has to be called
+// before the Pool is used.
+func (pool *Pool) Throttle(ops int) *Pool {
+	pool.throttle = newThrottle(ops)
+	return pool
+}
+
+// throttle limits the Fs calls to a rate, shared by all the goroutines. Each
+// call reserves the next free slot, so the goroutines wait in turn instead of
+// all sleeping for the same interval.
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newThrottle returns the throttle for ops calls per second, or nil for no
+// limit.
+func newThrottle(ops int) *throttle {
+	if ops <= 0 {
+		return nil
+	}
+	return &throttle{interval: time.Second / time.Duration(ops)}
+}
+
+// wait until the next call is allowed, or the ctx is done (the error).
+func (th *throttle) wait(ctx context.Context) error {
+	if th == nil {
+		return ctx.Err()
+	}
+	th.mu.Lock()
+	now := time.Now()
+	if th.next.Before(now) {
+		th.next = now
+	}
+	slot := th.next
+	th.next = th.next.Add(th.interval)
+	th.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitFs waits for the Options (or Pool) throttle, before an Fs call.
+func (opts *Options) waitFs(ctx context.Context) error {
+	opts.throttleOnce.Do(func() {
+		if opts.Pool != nil && opts.Pool.throttle != nil {
+			opts.throttle = opts.Pool.throttle
+		} else {
+			opts.throttle = newThrottle(opts.Throttle)
+		}
+	})
+	return opts.throttle.wait(ctx)
+}
@@ -0,0 +1,45 @@
+package tree
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is the minimum time between calls to Options.Progress
+const progressInterval = 100 * time.Millisecond
+
+// progressState is the count of visited nodes for Options.Progress, shared
+// by all the Visits with the same Options.
+type progressState struct {
+	dirs  int64
+	files int64
+	last  int64 // UnixNano of the last call
+	mu    sync.Mutex
+}
+
+// progress counts the node as visited, and calls Options.Progress if it's
+// been long enough since the last call.
+func (node *Node) progress(opts *Options) {
+	opts.progOnce.Do(func() { opts.prog = &progressState{} })
+	prog := opts.prog
+
+	var dirs, files int64
+	if node.IsDir() {
+		dirs = atomic.AddInt64(&prog.dirs, 1)
+		files = atomic.LoadInt64(&prog.files)
+	} else {
+		dirs = atomic.LoadInt64(&prog.dirs)
+		files = atomic.AddInt64(&prog.files, 1)
+	}
+
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&prog.last)
+	if now-last < int64(progressInterval) ||
+		!atomic.CompareAndSwapInt64(&prog.last, last, now) {
+		return
+	}
+	prog.mu.Lock()
+	defer prog.mu.Unlock()
+	opts.Progress(dirs, files, node.path)
+}
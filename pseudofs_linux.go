@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package tree
+
+import "syscall"
+
+// pseudoFsTypes are the statfs magic numbers of the kernel generated
+// filesystems. Note that devtmpfs has the same magic as tmpfs, so it can't be
+// told apart from /tmp and isn't included.
+var pseudoFsTypes = map[int64]string{
+	0x9fa0:     "proc",
+	0x62656572: "sysfs",
+	0x1cd1:     "devpts",
+	0x27e0eb:   "cgroup",
+	0x63677270: "cgroup2",
+	0x64626720: "debugfs",
+	0x74726163: "tracefs",
+	0x73636673: "securityfs",
+	0x6165676c: "pstore",
+	0xcafe4a11: "bpf",
+	0x62656570: "configfs",
+	0xf97cff8c: "selinuxfs",
+	0x19800202: "mqueue",
+	0x42494e4d: "binfmt_misc",
+	0x65735543: "fusectl",
+	0xde5e81e4: "efivarfs",
+	0x6e736673: "nsfs",
+}
+
+// pseudoFs returns the type of the filesystem at the path, if it's a pseudo
+// filesystem, or "".
+func pseudoFs(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return pseudoFsTypes[int64(st.Type)]
+}
@@ -0,0 +1,21 @@
+//go:build linux
+// +build linux
+
+package tree
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPseudoFs(t *testing.T) {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		t.Skip("no /proc")
+	}
+	if fstype := pseudoFs("/proc"); fstype != "proc" {
+		t.Errorf("pseudo: expected proc for /proc, got %q", fstype)
+	}
+	if fstype := pseudoFs("."); fstype != "" {
+		t.Errorf("pseudo: unexpected %q for .", fstype)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package tree
+
+// pseudoFs returns the type of the filesystem at the path, if it's a pseudo
+// filesystem, or "". It's only known on Linux.
+func pseudoFs(path string) string {
+	return ""
+}
@@ -0,0 +1,303 @@
+package tree
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// markIncomplete marks the dirs. with the ctx error (err) as partial,
+// instead of the error, for RunConfig.Partial. So the entries read before
+// it are printed. The entries that weren't read at all are removed.
+func markIncomplete(node *Node, err error) {
+	if node.err == err {
+		node.err = nil
+		node.partial = true
+	}
+	nodes := node.nodes[:0]
+	for _, nnode := range node.nodes {
+		if nnode.err == err && nnode.FileInfo == nil {
+			continue
+		}
+		markIncomplete(nnode, err)
+		nodes = append(nodes, nnode)
+	}
+	node.nodes = nodes
+}
+
+// addNode adds the node to the counts in the sum, but not to the dirs.,
+// files or bytes.
+func (sum *Summary) addNode(opts *Options, node *Node) {
+	if node.err != nil {
+		sum.Errors++
+		if os.IsPermission(node.err) {
+			sum.Denied++
+		}
+	}
+	sum.Hidden += int(node.hFiles)
+	if opts.DepthReport {
+		sum.addDepth(node)
+	}
+	if opts.AgeBuckets != nil {
+		sum.addAge(opts.AgeBuckets, node)
+	}
+	if opts.Policy != nil && node.policy != "" {
+		sum.Violations++
+	}
+	if opts.Savings && node.err == nil && node.saving != "" {
+		sum.Saved += saved(node)
+	}
+}
+
+// addTree adds the visited tree to the sum, and returns the number of dirs.
+// and files in it like Visit. The root isn't counted when it's a dir.
+func (sum *Summary) addTree(opts *Options, root *Node) (dirs, files int) {
+	p := opts.Sample
+	sampled := p > 0 && p < 1
+	var walk func(node *Node) (es estimateSums)
+	walk = func(node *Node) (es estimateSums) {
+		switch {
+		case node.IsDir():
+			if node.depth != 0 {
+				dirs++
+			}
+		case node.err == nil:
+			files++
+		}
+		sum.addNode(opts, node)
+		for _, nnode := range node.nodes {
+			nes := walk(nnode)
+			if sampled {
+				es.add(nnode, nes, p)
+			}
+		}
+		return es
+	}
+	es := walk(root)
+
+	sum.Bytes += NodeSize(root)
+	if sampled {
+		if sum.Estimate == nil {
+			sum.Estimate = &Estimate{}
+		}
+		sum.Estimate.Add(es.estimate(root))
+	}
+	return dirs, files
+}
+
+// Report returns the Summary of the visited trees, the counts Run prints
+// after them, see PrintReport.
+func Report(opts *Options, roots ...*Node) Summary {
+	var sum Summary
+	for _, root := range roots {
+		d, f := sum.addTree(opts, root)
+		sum.Dirs, sum.Files = sum.Dirs+d, sum.Files+f
+	}
+	return sum
+}
+
+// NormPath makes the OS path absolute, and if it's a symlink resolves it. So
+// the root of a tree is always shown as the real dir.
+func NormPath(root string) (string, error) {
+	ret, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	root = ret
+
+	fi, err := os.Lstat(root)
+	if err != nil {
+		return "", err
+	}
+
+	if (fi.Mode() & os.ModeSymlink) != 0 {
+		nr, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return "", err
+		}
+		return nr, nil
+	}
+
+	return root, nil
+}
+
+// RunConfig is the configuration for Run.
+type RunConfig struct {
+	Options *Options
+	// Paths are the roots of the trees, the default is "."
+	Paths []string
+	// NormPath uses NormPath on the roots, if it doesn't fail.
+	NormPath bool
+	// Format is the output format (see SetOutputFormat), if it's "" then
+	// the format for the OutputName extension is used, if any, otherwise the
+	// Options are used as is.
+	Format     string
+	OutputName string
+	// Partial prints what was visited when the ctx is done, and sets
+	// Summary.Incomplete, instead of returning the ctx error.
+	Partial bool
+}
+
+// Run prints the trees for the paths, with the header/footer and report for
+// the output format, like cmd/tree. The roots are visited at once, but
+// printed in order. The counts from the report are returned.
+func Run(ctx context.Context, conf RunConfig) (Summary, error) {
+	opts := conf.Options
+	if opts == nil || opts.Fs == nil || opts.OutFile == nil {
+		return Summary{}, errors.New("tree: Options.Fs and Options.OutFile are required")
+	}
+	if opts.Encoding == "" {
+		return run(ctx, conf)
+	}
+
+	enc, err := EncodeOutput(opts.OutFile, opts.Encoding)
+	if err != nil {
+		return Summary{}, err
+	}
+	out := opts.OutFile
+	opts.OutFile = enc
+	sum, err := run(ctx, conf)
+	opts.OutFile = out
+	if cerr := enc.Close(); err == nil {
+		err = cerr
+	}
+	return sum, err
+}
+
+// run is Run, with the output already encoded.
+func run(ctx context.Context, conf RunConfig) (Summary, error) {
+	var sum Summary
+	opts := conf.Options
+	oformat := conf.Format
+	if oformat == "" {
+		oformat = OutputFormatForFile(conf.OutputName)
+	}
+	if oformat != "" {
+		if err := opts.SetOutputFormat(oformat); err != nil {
+			return sum, err
+		}
+	}
+	if opts.UsageReport != "" {
+		if _, err := TopUsage(opts.UsageReport); err != nil {
+			return sum, err
+		}
+	}
+	if opts.Checksum != "" {
+		if _, err := ParseChecksum(opts.Checksum); err != nil {
+			return sum, err
+		}
+	}
+	if (opts.Stats || opts.StatsOnly) && (opts.Stream || !opts.textOutput()) {
+		return sum, errors.New("stats are only for the text output, without streaming")
+	}
+	paths := conf.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if conf.NormPath {
+		paths = append([]string(nil), paths...)
+		for i := range paths {
+			if d, err := NormPath(paths[i]); err == nil {
+				paths[i] = d
+			}
+		}
+	}
+
+	if opts.Stream && opts.textOutput() {
+		return runStream(ctx, opts, paths, conf.Partial)
+	}
+
+	type rootResult struct {
+		inf  *Node
+		d, f int
+	}
+	roots := make([]rootResult, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			if ctx.Err() != nil && !conf.Partial {
+				return
+			}
+			inf := New(path)
+			d, f := inf.VisitContext(ctx, opts)
+			roots[i] = rootResult{inf, d, f}
+		}(i, path)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		if !conf.Partial {
+			return sum, err
+		}
+		sum.Incomplete = true
+		for _, root := range roots {
+			markIncomplete(root.inf, err)
+		}
+	}
+
+	PrintHeader(opts, "tree "+strings.Join(paths, " "))
+	for _, root := range roots {
+		sum.Dirs += root.d
+		sum.Files += root.f
+		sum.addTree(opts, root.inf)
+		if !opts.StatsOnly {
+			root.inf.Print(opts)
+		}
+	}
+	if opts.NoReport {
+		PrintFooter(opts, nil)
+	} else {
+		PrintFooter(opts, &sum)
+	}
+
+	// The usage table is only for the text output
+	if opts.UsageReport != "" && opts.textOutput() {
+		infs := make([]*Node, len(roots))
+		for i, root := range roots {
+			infs[i] = root.inf
+		}
+		usage, _ := TopUsage(opts.UsageReport, infs...)
+		printUsage(opts, opts.OutFile, usage)
+	}
+	if opts.Stats || opts.StatsOnly {
+		infs := make([]*Node, len(roots))
+		for i, root := range roots {
+			infs[i] = root.inf
+		}
+		top := opts.StatsTop
+		if top <= 0 {
+			top = statsTop
+		}
+		printStats(opts, opts.OutFile, TreeStats(top, infs...))
+	}
+	return sum, nil
+}
+
+// runStream is Run for Options.Stream, the roots are streamed in order.
+func runStream(ctx context.Context, opts *Options, paths []string,
+	partial bool) (Summary, error) {
+	var sum Summary
+	PrintHeader(opts, "tree "+strings.Join(paths, " "))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			if !partial {
+				return sum, err
+			}
+			break
+		}
+		inf := New(path)
+		inf.ctx = ctx
+		inf.stream(opts, &sum)
+	}
+	sum.Incomplete = ctx.Err() != nil
+	if opts.NoReport {
+		PrintFooter(opts, nil)
+	} else {
+		PrintFooter(opts, &sum)
+	}
+	return sum, nil
+}
@@ -0,0 +1,125 @@
+package tree
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Estimate is the estimated totals for a tree visited with Options.Sample,
+// the errors are the +/- for a ~95% confidence interval.
+type Estimate struct {
+	Dirs     float64 `json:"directories"`
+	Files    float64 `json:"files"`
+	Bytes    float64 `json:"size"`
+	DirsErr  float64 `json:"directories_error"`
+	FilesErr float64 `json:"files_error"`
+	BytesErr float64 `json:"size_error"`
+}
+
+// ParseSample converts a percentage (1%) or a fraction (0.01) to the
+// fraction for Options.Sample.
+func ParseSample(s string) (float64, error) {
+	num, div := s, 1.0
+	if strings.HasSuffix(s, "%") {
+		num, div = strings.TrimSuffix(s, "%"), 100
+	}
+	val, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || val/div <= 0 || val/div > 1 {
+		return 0, fmt.Errorf("bad sample %q, expected 0%% < N <= 100%%", s)
+	}
+	return val / div, nil
+}
+
+// sampled returns if the dir. is in the sample, it's from a hash of the path
+// so the same dirs. are sampled each time.
+func sampled(opts *Options, path string) bool {
+	if opts.Sample <= 0 || opts.Sample >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	// FNV alone is too alike for a dir. and its parent, so mix the bits
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x) < opts.Sample*math.MaxUint64
+}
+
+// Add the other estimate to this one, the errors are for independent trees.
+func (est *Estimate) Add(o Estimate) {
+	est.Dirs += o.Dirs
+	est.Files += o.Files
+	est.Bytes += o.Bytes
+	est.DirsErr = math.Hypot(est.DirsErr, o.DirsErr)
+	est.FilesErr = math.Hypot(est.FilesErr, o.FilesErr)
+	est.BytesErr = math.Hypot(est.BytesErr, o.BytesErr)
+}
+
+// EstimateTree returns the estimated totals for the tree visited with
+// Options.Sample. Each dir. below the root was read with the probability
+// Sample, at each level, so the totals for a dir. are from the sampled
+// subdirs. scaled by 1/Sample (a multistage estimate).
+func EstimateTree(opts *Options, node *Node) Estimate {
+	p := opts.Sample
+	if p <= 0 || p > 1 {
+		p = 1
+	}
+	return estimateDir(node, p).estimate(node)
+}
+
+// estimateSums are the estimated dirs., files and bytes under a dir. and
+// their variances.
+type estimateSums struct {
+	tot, vars [3]float64
+}
+
+// add the entry of the dir. to the sums, nes are the sums for the entry
+// when it's a dir. For each sampled subdir. the variance is from not
+// sampling it, and from its own estimate.
+func (es *estimateSums) add(nnode *Node, nes estimateSums, p float64) {
+	if nnode.err != nil {
+		return
+	}
+	if !nnode.IsDir() {
+		es.tot[1]++
+		es.tot[2] += float64(nnode.Size())
+		return
+	}
+	nes.tot[0]++ // The subdir. itself
+	for i := range es.tot {
+		es.tot[i] += nes.tot[i] / p
+		es.vars[i] += ((1-p)*nes.tot[i]*nes.tot[i] + nes.vars[i]) / (p * p)
+	}
+}
+
+// estimate returns the Estimate for the node, from its sums.
+func (es estimateSums) estimate(node *Node) Estimate {
+	if !node.IsDir() {
+		return Estimate{Files: 1, Bytes: float64(node.Size())}
+	}
+	const z95 = 1.96
+	return Estimate{
+		Dirs:     es.tot[0],
+		Files:    es.tot[1],
+		Bytes:    es.tot[2],
+		DirsErr:  z95 * math.Sqrt(es.vars[0]),
+		FilesErr: z95 * math.Sqrt(es.vars[1]),
+		BytesErr: z95 * math.Sqrt(es.vars[2]),
+	}
+}
+
+// estimateDir returns the sums for the dir., see Options.Sample.
+func estimateDir(node *Node, p float64) (es estimateSums) {
+	for _, nnode := range node.nodes {
+		var nes estimateSums
+		if nnode.IsDir() {
+			nes = estimateDir(nnode, p)
+		}
+		es.add(nnode, nes, p)
+	}
+	return es
+}
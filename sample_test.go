@@ -0,0 +1,39 @@
+package tree
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	if frac, err := ParseSample("25%"); err != nil || frac != 0.25 {
+		t.Errorf("parse: expected 0.25, got %v (%v)", frac, err)
+	}
+	if _, err := ParseSample("0%"); err == nil {
+		t.Errorf("parse: expected an error for 0%%")
+	}
+
+	mfs := NewMapFs()
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		mfs.AddFile("root/"+name+"/x", make([]byte, 10), 0644, testTime)
+	}
+	opts := &Options{}
+	inf := visitMapFs(mfs, opts)
+	if est := EstimateTree(opts, inf); est != (Estimate{Dirs: 8, Files: 8, Bytes: 80}) {
+		t.Errorf("everything: got %+v", est)
+	}
+
+	opts = &Options{Fs: mfs, OutFile: out, Sample: 0.5}
+	inf = New("root")
+	d, f := inf.Visit(opts)
+	if d != f || d == 0 || d == 8 {
+		t.Fatalf("sample: expected some of the dirs., got (%d, %d)", d, f)
+	}
+	// Each sampled dir. stands for 2, and the variance is 2 per dir.
+	est := EstimateTree(opts, inf)
+	kept := float64(d)
+	if est.Dirs != 2*kept || est.Files != 2*kept || est.Bytes != 20*kept ||
+		math.Abs(est.DirsErr-1.96*math.Sqrt(2*kept)) > 1e-9 {
+		t.Errorf("sample %v: got %+v", kept, est)
+	}
+}
@@ -0,0 +1,54 @@
+package tree
+
+import "fmt"
+
+// savingMinSize is the smallest file checked, smaller files can be stored
+// in the metadata (btrfs inline extents) with no blocks at all.
+const savingMinSize = 4096
+
+// saved returns the bytes the file doesn't use on disk, if it's at least
+// half its size (sparse or compressed), otherwise 0.
+func saved(node *Node) int64 {
+	if !node.Mode().IsRegular() || node.Size() < savingMinSize {
+		return 0
+	}
+	ok, alloc := getAllocated(node)
+	if !ok || alloc*2 > node.Size() {
+		return 0
+	}
+	return node.Size() - alloc
+}
+
+// savingKind returns why the file uses less disk than its size, the holes
+// are checked if the OS can.
+func savingKind(node *Node) string {
+	if node.virtual {
+		return "sparse/compressed"
+	}
+	ok, holes := fileHoles(node.path)
+	switch {
+	case !ok:
+		return "sparse/compressed"
+	case holes:
+		return "sparse"
+	}
+	return "compressed"
+}
+
+// checkSavings marks the file if it's sparse or compressed.
+func (node *Node) checkSavings(opts *Options) {
+	if !opts.Savings && !opts.Sparse {
+		return
+	}
+	num := saved(node)
+	if num == 0 {
+		return
+	}
+	kind := savingKind(node)
+	if opts.Sparse && kind != "compressed" {
+		node.sparse = true // Not shown with Savings, which says the same
+	}
+	if opts.Savings {
+		node.saving = fmt.Sprintf("%s, %d%% saved", kind, num*100/node.Size())
+	}
+}
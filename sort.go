@@ -1,8 +1,11 @@
 package tree
 
+import "sync"
+
 func (n Nodes) Len() int      { return len(n) }
 func (n Nodes) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
 
+// ByFunc is a sort.Interface for the nodes, ordered by the SortFunc.
 type ByFunc struct {
 	Nodes
 	Fn SortFunc
@@ -12,14 +15,65 @@ func (b ByFunc) Less(i, j int) bool {
 	return b.Fn(b.Nodes[i], b.Nodes[j])
 }
 
+// SortFunc returns true if f1 sorts before f2, for Options.SortBy.
 type SortFunc func(f1, f2 *Node) bool
 
+// sortKey is the key of an entry for SortByKey, it's only set once.
+type sortKey struct {
+	once sync.Once
+	k    float64
+}
+
+// SortByKey returns a SortFunc ordering the entries by the key, from the
+// smallest. Like a score from a database, it's only called once per entry
+// (the dirs. can be sorted at the same time, so it must be safe for that).
+// The keys are kept for all the entries sorted, until the SortFunc is freed,
+// so use a new one for each Visit.
+func SortByKey(key func(n *Node) float64) SortFunc {
+	var mu sync.Mutex
+	keys := make(map[*Node]*sortKey)
+	get := func(n *Node) float64 {
+		mu.Lock()
+		sk := keys[n]
+		if sk == nil {
+			sk = &sortKey{}
+			keys[n] = sk
+		}
+		mu.Unlock()
+		sk.once.Do(func() { sk.k = key(n) }) // Not holding mu
+		return sk.k
+	}
+	return func(f1, f2 *Node) bool {
+		return get(f1) < get(f2)
+	}
+}
+
 func ModSort(nf1, nf2 *Node) bool {
 	f1 := nf1.FileInfo
 	f2 := nf2.FileInfo
 	return f1.ModTime().Before(f2.ModTime())
 }
 
+// BTimeSort sorts by the birth time, the files without one are the oldest.
+func BTimeSort(nf1, nf2 *Node) bool {
+	return nf1.btime.Before(nf2.btime)
+}
+
+// ATimeSort sorts by the last access time, or ModSort if it isn't known.
+func ATimeSort(nf1, nf2 *Node) bool {
+	t1, ok1 := getAccessTime(nf1)
+	t2, ok2 := getAccessTime(nf2)
+	if !ok1 || !ok2 {
+		return ModSort(nf1, nf2)
+	}
+	return t1.Before(t2)
+}
+
+// NewestModSort is ModSort, using the newest mtime under dirs.
+func NewestModSort(nf1, nf2 *Node) bool {
+	return NewestModTime(nf1).Before(NewestModTime(nf2))
+}
+
 // This is a secondary sort function...
 func DirSort(nf1, nf2 *Node, nxt SortFunc) bool {
 	f1 := nf1.FileInfo
@@ -30,10 +84,37 @@ func DirSort(nf1, nf2 *Node, nxt SortFunc) bool {
 	return f1.IsDir() && !f2.IsDir()
 }
 
+// DirLastSort is DirSort reversed, the files are before the dirs.
+func DirLastSort(nf1, nf2 *Node, nxt SortFunc) bool {
+	if nf1.IsDir() == nf2.IsDir() {
+		return nxt(nf1, nf2)
+	}
+	return DirSort(nf2, nf1, nxt)
+}
+
+// CountSort sorts the dirs. by the number of entries under them, and the
+// files by size. The files are before the dirs., so reversed the dirs. with
+// the most entries are first.
+func CountSort(f1, f2 *Node) bool {
+	if f1.IsDir() != f2.IsDir() {
+		return !f1.IsDir()
+	}
+	if !f1.IsDir() {
+		return f1.Size() < f2.Size()
+	}
+	return countEntries(f1) < countEntries(f2)
+}
+
 func SizeSort(f1, f2 *Node) bool {
 	return NodeSize(f1) < NodeSize(f2)
 }
 
+// readDirSort keeps the ReadDir order for NoSort, as the entries visited by
+// goroutines are added when they finish.
+func readDirSort(f1, f2 *Node) bool {
+	return f1.index < f2.index
+}
+
 func NameSort(f1, f2 *Node) bool {
 	return f1.Name() < f2.Name()
 }
@@ -18,3 +18,22 @@ func getStat(fi os.FileInfo) (ok bool, inode, device, uid, gid uint64) {
 	}
 	return true, uint64(stat.Ino), uint64(stat.Dev), uint64(stat.Uid), uint64(stat.Gid)
 }
+
+// getNlink returns the number of hard links to the file.
+func getNlink(fi os.FileInfo) (ok bool, nlink uint64) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, 0
+	}
+	return true, uint64(stat.Nlink)
+}
+
+// getAllocated returns the bytes allocated on disk for the file, which can
+// be less than the size for sparse or compressed files.
+func getAllocated(fi os.FileInfo) (ok bool, size int64) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, 0
+	}
+	return true, int64(stat.Blocks) * 512
+}
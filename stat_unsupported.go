@@ -7,3 +7,11 @@ import "os"
 func getStat(fi os.FileInfo) (ok bool, inode, device, uid, gid uint64) {
 	return false, 0, 0, 0, 0
 }
+
+func getNlink(fi os.FileInfo) (ok bool, nlink uint64) {
+	return false, 0
+}
+
+func getAllocated(fi os.FileInfo) (ok bool, size int64) {
+	return false, 0
+}
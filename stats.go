@@ -0,0 +1,208 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// statsLimits are the upper limits of the size histogram buckets, the
+// empty files have their own bucket and the last is everything bigger.
+var statsLimits = []struct {
+	size  int64
+	label string
+}{
+	{KB, "1K"}, {10 * KB, "10K"}, {100 * KB, "100K"}, {MB, "1M"},
+	{10 * MB, "10M"}, {100 * MB, "100M"}, {GB, "1G"},
+}
+
+// statsBarWidth is the width of the biggest histogram bar
+const statsBarWidth = 40
+
+// statsTop is the default Options.StatsTop
+const statsTop = 10
+
+// SizeBucket is the files with sizes in the range of the Label, for the
+// histogram.
+type SizeBucket struct {
+	Label string
+	Files int
+	Bytes int64
+}
+
+// ExtUsage is the files with an extension, "" is the files without one.
+type ExtUsage struct {
+	Ext   string
+	Files int
+	Bytes int64
+}
+
+// Stats are the aggregate statistics of the trees, for Options.Stats.
+type Stats struct {
+	Dirs     int
+	Files    int
+	Symlinks int
+	Bytes    int64
+	Sizes    []SizeBucket
+	// Largest files and dirs., and the extensions with the most bytes
+	Largest     []Usage
+	LargestDirs []Usage
+	Exts        []ExtUsage
+}
+
+// sizeBucket returns the index of the histogram bucket for the size.
+func sizeBucket(size int64) int {
+	if size == 0 {
+		return 0
+	}
+	for i, limit := range statsLimits {
+		if size < limit.size {
+			return i + 1
+		}
+	}
+	return len(statsLimits) + 1
+}
+
+// newSizeBuckets returns the empty histogram, with the labels.
+func newSizeBuckets() []SizeBucket {
+	buckets := []SizeBucket{{Label: "0"}}
+	prev := "1"
+	for _, limit := range statsLimits {
+		buckets = append(buckets, SizeBucket{Label: prev + "-" + limit.label})
+		prev = limit.label
+	}
+	return append(buckets, SizeBucket{Label: ">" + prev})
+}
+
+// TreeStats returns the statistics of the visited trees, with the top
+// largest files, dirs. and extensions.
+func TreeStats(top int, roots ...*Node) *Stats {
+	st := &Stats{Sizes: newSizeBuckets()}
+	exts := make(map[string]*ExtUsage)
+	var files, dirs []Usage
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		switch {
+		case node.IsDir():
+			if node.depth != 0 {
+				st.Dirs++
+				dirs = append(dirs, Usage{node.path, countEntries(node),
+					NodeSize(node)})
+			}
+		case node.Mode()&os.ModeSymlink != 0:
+			st.Symlinks++
+		case node.err != nil:
+		default:
+			st.Files++
+			if node.linkDup { // Like the report, hard links count once
+				break
+			}
+			size := node.Size()
+			st.Bytes += size
+			bucket := &st.Sizes[sizeBucket(size)]
+			bucket.Files++
+			bucket.Bytes += size
+			files = append(files, Usage{node.path, 1, size})
+
+			ext := strings.ToLower(filepath.Ext(node.Name()))
+			if exts[ext] == nil {
+				exts[ext] = &ExtUsage{Ext: ext}
+			}
+			exts[ext].Files++
+			exts[ext].Bytes += size
+		}
+		for _, nnode := range node.nodes {
+			walk(nnode)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+
+	bySize := func(usage []Usage) []Usage {
+		sort.SliceStable(usage, func(i, j int) bool {
+			if usage[i].Bytes != usage[j].Bytes {
+				return usage[i].Bytes > usage[j].Bytes
+			}
+			return usage[i].Path < usage[j].Path
+		})
+		if len(usage) > top {
+			usage = usage[:top]
+		}
+		return usage
+	}
+	st.Largest = bySize(files)
+	st.LargestDirs = bySize(dirs)
+
+	for _, eu := range exts {
+		st.Exts = append(st.Exts, *eu)
+	}
+	sort.Slice(st.Exts, func(i, j int) bool {
+		if st.Exts[i].Bytes != st.Exts[j].Bytes {
+			return st.Exts[i].Bytes > st.Exts[j].Bytes
+		}
+		return st.Exts[i].Ext < st.Exts[j].Ext
+	})
+	if len(st.Exts) > top {
+		st.Exts = st.Exts[:top]
+	}
+	return st
+}
+
+// printStats prints the statistics, after the report.
+func printStats(opts *Options, w io.Writer, st *Stats) {
+	fmt.Fprintf(w, "\n%d directories, %d files, %d symlinks, %s size\n",
+		st.Dirs, st.Files, st.Symlinks,
+		strings.TrimSpace(FormatSize(opts, st.Bytes)))
+
+	width, most := 0, 0
+	for _, bucket := range st.Sizes {
+		if len(bucket.Label) > width {
+			width = len(bucket.Label)
+		}
+		if bucket.Files > most {
+			most = bucket.Files
+		}
+	}
+	fmt.Fprintf(w, "\n%-*s %10s\n", width, "sizes", "files")
+	for _, bucket := range st.Sizes {
+		bar := 0
+		if most > 0 {
+			bar = (bucket.Files*statsBarWidth + most - 1) / most
+		}
+		line := fmt.Sprintf("%-*s %10d  %s", width, bucket.Label, bucket.Files,
+			strings.Repeat("#", bar))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	size := FormatSize(opts, 0)
+	if len(st.Largest) > 0 {
+		fmt.Fprintf(w, "\n%*s  %s\n", len(size), "size", "largest files")
+		for _, u := range st.Largest {
+			fmt.Fprintf(w, "%s  %s\n", FormatSize(opts, u.Bytes), u.Path)
+		}
+	}
+	if len(st.LargestDirs) > 0 {
+		fmt.Fprintf(w, "\n%10s %*s  %s\n", "entries", len(size), "size",
+			"largest directories")
+		for _, u := range st.LargestDirs {
+			fmt.Fprintf(w, "%10d %s  %s\n", u.Entries, FormatSize(opts, u.Bytes),
+				u.Path)
+		}
+	}
+	if len(st.Exts) > 0 {
+		fmt.Fprintf(w, "\n%10s %*s  %s\n", "files", len(size), "size",
+			"extension")
+		for _, eu := range st.Exts {
+			ext := eu.Ext
+			if ext == "" {
+				ext = "(none)"
+			}
+			fmt.Fprintf(w, "%10d %s  %s\n", eu.Files, FormatSize(opts, eu.Bytes),
+				ext)
+		}
+	}
+}
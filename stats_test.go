@@ -0,0 +1,35 @@
+package tree
+
+import "testing"
+
+func TestTreeStats(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a/b.txt", make([]byte, 100), 0644, testTime).
+		AddFile("root/a/e", nil, 0644, testTime).
+		AddFile("root/c/d.TXT", make([]byte, 2000), 0644, testTime).
+		AddFile("root/g.go", make([]byte, 20000), 0644, testTime).
+		AddSymlink("root/l", "g.go", testTime)
+
+	st := TreeStats(2, visitMapFs(mfs, &Options{}))
+	if st.Dirs != 2 || st.Files != 4 || st.Symlinks != 1 || st.Bytes != 22100 {
+		t.Errorf("counts: got %+v", st)
+	}
+	for i, files := range []int{1, 1, 1, 1, 0} {
+		if st.Sizes[i].Files != files {
+			t.Errorf("sizes: got %+v", st.Sizes)
+			break
+		}
+	}
+	expected := []Usage{{"root/g.go", 1, 20000}, {"root/c/d.TXT", 1, 2000}}
+	if len(st.Largest) != 2 || st.Largest[0] != expected[0] ||
+		st.Largest[1] != expected[1] {
+		t.Errorf("largest: got %+v expected %+v", st.Largest, expected)
+	}
+	if len(st.LargestDirs) != 2 || st.LargestDirs[0].Path != "root/c" {
+		t.Errorf("largest dirs: got %+v", st.LargestDirs)
+	}
+	exts := []ExtUsage{{".go", 1, 20000}, {".txt", 2, 2100}}
+	if len(st.Exts) != 2 || st.Exts[0] != exts[0] || st.Exts[1] != exts[1] {
+		t.Errorf("exts: got %+v expected %+v", st.Exts, exts)
+	}
+}
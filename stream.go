@@ -0,0 +1,97 @@
+package tree
+
+// Stream visits and prints the tree at the same time, each dir. is printed
+// as soon as its entries have been read and sorted. So it's only for the text
+// output, dirs. don't have sizes, there's no dynamic leveling (-L -1 shows
+// everything) or JoinSingle and the columns are only aligned within a dir.
+// Hard linked files are counted once in the report, like Visit.
+func (node *Node) Stream(opts *Options) (dirs, files int) {
+	var sum Summary
+	node.stream(opts, &sum)
+	return sum.Dirs, sum.Files
+}
+
+// stream adds the counts for the report to sum, as the tree is printed.
+func (node *Node) stream(opts *Options, sum *Summary) {
+	node.shallow = true
+	d, f, _ := visitNode(opts, node)
+	sum.Dirs, sum.Files = sum.Dirs+d, sum.Files+f
+	seen := make(map[linkKey]bool)
+	sum.Bytes += streamSize(opts, node, seen)
+
+	layout := &Layout{}
+	layout.addLevels(opts, node)
+	node.streamNode(opts, "", "", layout, sum, seen)
+}
+
+// streamSize returns the size of the file for the report, a hard link to a
+// file already in seen is 0 (see dedupLinks).
+func streamSize(opts *Options, node *Node, seen map[linkKey]bool) int64 {
+	if node.IsDir() || node.err != nil {
+		return 0
+	}
+	if key, ok := hardLink(node); ok && !opts.CountLinks {
+		if seen[key] {
+			return 0
+		}
+		seen[key] = true
+	}
+	return node.Size()
+}
+
+// streamNode prints the node, and then reads and prints the children.
+func (node *Node) streamNode(opts *Options, indentc, indentn string,
+	layout *Layout, sum *Summary, seen map[linkKey]bool) {
+	sum.addNode(opts, node)
+	pnode, _ := node.printLine(opts, indentc, layout)
+	if opts.StreamTotals != nil {
+		opts.StreamTotals(node, *sum)
+	}
+	node = pnode
+	if node == nil {
+		return
+	}
+
+	if node.IsDir() && node.shallow {
+		if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
+			return
+		}
+		node.vs = nil // The children are visited like a root
+		d, f := node.visitDir(opts)
+		sum.Dirs, sum.Files = sum.Dirs+d, sum.Files+f
+		sum.Bytes += node.hSize
+		if hl := node.hLinks; hl != nil {
+			for _, nnode := range byName(hl.nodes) {
+				sum.Bytes += streamSize(opts, nnode, seen)
+			}
+			node.hLinks = nil
+		}
+		sum.Hidden += int(node.hFiles)
+		if node.err != nil {
+			sum.Errors++
+			node.printLine(opts, indentn, layout)
+		}
+		layout = &Layout{}
+		layout.addLevels(opts, node)
+	}
+
+	// Print tree structure, like print
+	g := opts.graphics()
+	nodes := node.sortedNodes(opts)
+	for i, nnode := range nodes {
+		add := g.Vertical
+		if opts.NoIndent {
+			add = ""
+		} else {
+			if i == len(nodes)-1 {
+				indentc = indentn + g.Last
+				add = g.Space
+			} else {
+				indentc = indentn + g.Branch
+			}
+		}
+		sum.Bytes += streamSize(opts, nnode, seen)
+		nnode.streamNode(opts, indentc, indentn+add, layout, sum, seen)
+	}
+	node.nodes = nil // Printed, so they aren't needed
+}
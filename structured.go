@@ -0,0 +1,185 @@
+package tree
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// linkTarget returns the target of a symlink node, or "".
+func linkTarget(opts *Options, node *Node) string {
+	if node.Mode()&os.ModeSymlink == 0 || node.virtual {
+		return ""
+	}
+	target, err := readlink(opts, node.path)
+	if err != nil {
+		return ""
+	}
+	return target
+}
+
+// linkTargetText returns the annotation with the mode, size and date of the
+// symlink target, for Options.LinkTargets.
+func linkTargetText(opts *Options, fi os.FileInfo) string {
+	size := fmt.Sprintf("%d", fi.Size())
+	if opts.UnitSize {
+		size = formatBytes(fi.Size())
+	}
+	return fmt.Sprintf(" [%s %s %s]", fi.Mode(), size,
+		formatModTime(opts, fi.ModTime()))
+}
+
+// structName returns the name for the node in the structured outputs
+func structName(opts *Options, node *Node) string {
+	if node.depth == 0 || opts.FullPath {
+		return rootName(opts, node)
+	}
+	return node.Name()
+}
+
+// structChildren returns the children to print in the structured outputs
+func structChildren(opts *Options, node *Node) Nodes {
+	if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
+		return nil
+	}
+	return node.sortedNodes(opts)
+}
+
+// jsonNode is a node in the JSON output, like GNU tree -J.
+type jsonNode struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name"`
+	Target   string            `json:"target,omitempty"`
+	Size     int64             `json:"size"`
+	Mode     string            `json:"mode"`
+	ModTime  time.Time         `json:"mtime"`
+	Checksum string            `json:"checksum,omitempty"`
+	Err      string            `json:"error,omitempty"`
+	Extra    map[string]string `json:"extra,omitempty"`
+	Contents []*jsonNode       `json:"contents,omitempty"`
+}
+
+func newJSONNode(opts *Options, node *Node) *jsonNode {
+	jn := &jsonNode{
+		Type:    structType(node),
+		Name:    structName(opts, node),
+		Target:  linkTarget(opts, node),
+		Size:    NodeSize(node),
+		Mode:    node.Mode().String(),
+		ModTime: node.ModTime(),
+		Extra:   node.extra,
+	}
+	jn.Checksum = node.csum
+	if node.err != nil {
+		jn.Err = node.err.Error()
+		return jn
+	}
+	for _, nnode := range structChildren(opts, node) {
+		jn.Contents = append(jn.Contents, newJSONNode(opts, nnode))
+	}
+	return jn
+}
+
+// structType returns the type of the node, using the GNU tree names.
+func structType(node *Node) string {
+	switch t := nodeType(node); t {
+	case "dir":
+		return "directory"
+	case "symlink":
+		return "link"
+	default:
+		return t
+	}
+}
+
+// printJSON prints the node as a JSON object in the list of trees.
+func (node *Node) printJSON(opts *Options) {
+	data, err := json.Marshal(newJSONNode(opts, node))
+	if err != nil {
+		return
+	}
+	if opts.printed > 0 {
+		fmt.Fprintln(opts.OutFile, ",")
+	}
+	fmt.Fprintf(opts.OutFile, "  %s", data)
+}
+
+// xmlNode is a node in the XML output, like GNU tree -X.
+type xmlNode struct {
+	XMLName  xml.Name
+	Name     string     `xml:"name,attr"`
+	Target   string     `xml:"target,attr,omitempty"`
+	Size     int64      `xml:"size,attr"`
+	Mode     string     `xml:"mode,attr"`
+	ModTime  string     `xml:"mtime,attr"`
+	Checksum string     `xml:"checksum,attr,omitempty"`
+	Err      string     `xml:"error,omitempty"`
+	Extra    []xmlExtra `xml:"extra"`
+	Contents []*xmlNode `xml:""`
+}
+
+func newXMLNode(opts *Options, node *Node) *xmlNode {
+	xn := &xmlNode{
+		XMLName: xml.Name{Local: structType(node)},
+		Name:    structName(opts, node),
+		Target:  linkTarget(opts, node),
+		Size:    NodeSize(node),
+		Mode:    node.Mode().String(),
+		ModTime: node.ModTime().Format(time.RFC3339),
+		Extra:   xmlAnnotations(node),
+	}
+	xn.Checksum = node.csum
+	if node.err != nil {
+		xn.Err = node.err.Error()
+		return xn
+	}
+	for _, nnode := range structChildren(opts, node) {
+		xn.Contents = append(xn.Contents, newXMLNode(opts, nnode))
+	}
+	return xn
+}
+
+// printXML prints the node as an XML element in the tree element.
+func (node *Node) printXML(opts *Options) {
+	data, err := xml.MarshalIndent(newXMLNode(opts, node), "  ", "  ")
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(opts.OutFile, "%s\n", data)
+}
+
+// mdEscaper escapes the characters markdown would otherwise interpret
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`)
+
+// printMarkdown prints the node as a nested markdown list.
+func (node *Node) printMarkdown(opts *Options, layout *Layout) {
+	indent := strings.Repeat("  ", node.depth)
+	name := mdEscaper.Replace(structName(opts, node))
+	if node.IsDir() {
+		name = "**" + name + "/**"
+	}
+	if target := linkTarget(opts, node); target != "" {
+		name += " -> " + mdEscaper.Replace(target)
+	}
+	if node.err != nil {
+		err := node.err.Error()
+		if msgs := strings.Split(err, ": "); len(msgs) > 1 {
+			err = msgs[1]
+		}
+		name += " \\[" + mdEscaper.Replace(err) + "\\]"
+	} else if props := node.Props(opts, layout); len(props) > 0 {
+		name += " `" + strings.Join(props, " ") + "`"
+	}
+	fmt.Fprintf(opts.OutFile, "%s- %s\n", indent, name)
+	if node.err != nil {
+		return
+	}
+	for _, nnode := range structChildren(opts, node) {
+		nnode.printMarkdown(opts, layout)
+	}
+}
@@ -0,0 +1,70 @@
+package tree
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// clone returns a copy of the node and the nodes under it, as if it was
+// visited at the depth, without the nodes that skip returns true for. The
+// cached sizes/times are reset, as the children can be different.
+func (node *Node) clone(depth int, skip func(n *Node) bool) *Node {
+	nnode := *node
+	nnode.depth = depth
+	nnode.dSize = 0
+	nnode.dUsage = 0
+	nnode.dCount = 0
+	nnode.newest = time.Time{}
+	nnode.vs = nil
+	if node.extra != nil {
+		nnode.extra = make(map[string]string, len(node.extra))
+		for k, v := range node.extra {
+			nnode.extra[k] = v
+		}
+	}
+	if node.nodes != nil {
+		nnode.nodes = make(Nodes, 0, len(node.nodes))
+		for _, child := range node.nodes {
+			if skip != nil && skip(child) {
+				continue
+			}
+			nnode.nodes = append(nnode.nodes, child.clone(depth+1, skip))
+		}
+	}
+	return &nnode
+}
+
+// Subtree returns a copy of the node at the path in the visited tree, as a
+// root, or nil if it isn't there. The path is relative to the root, or the
+// full path as given to the Fs. So one visit can be printed as many trees.
+func (node *Node) Subtree(path string) *Node {
+	rel := filepath.Clean(path)
+	if r, err := filepath.Rel(node.path, path); err == nil &&
+		!strings.HasPrefix(r, "..") {
+		rel = r
+	}
+	found := node
+	if rel != "." {
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			var next *Node
+			for _, nnode := range found.nodes {
+				if nnode.Name() == name {
+					next = nnode
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			found = next
+		}
+	}
+	return found.clone(0, nil)
+}
+
+// Prune returns a copy of the visited tree, without the nodes that pred
+// returns true for and everything under them. The root is always kept.
+func (node *Node) Prune(pred func(n *Node) bool) *Node {
+	return node.clone(node.depth, pred)
+}
@@ -0,0 +1,174 @@
+// Package tarfs is a tree.Fs for the contents of a (maybe compressed) tar
+// archive, so it can be shown without extracting it.
+package tarfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Fs is a tree.Fs for a tar archive, paths are under the root given to
+// Open/New.
+type Fs struct {
+	root  string
+	infos map[string]os.FileInfo
+	dirs  map[string][]string
+	links map[string]string
+}
+
+// dirInfo is the FileInfo for dirs. that aren't in the archive, but are
+// needed for the entries in it (and the root).
+type dirInfo struct {
+	name  string
+	mtime time.Time
+}
+
+func (d *dirInfo) Name() string       { return d.name }
+func (d *dirInfo) Size() int64        { return 0 }
+func (d *dirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d *dirInfo) ModTime() time.Time { return d.mtime }
+func (d *dirInfo) IsDir() bool        { return true }
+func (d *dirInfo) Sys() interface{}   { return nil }
+
+// Open reads the tar archive at the path, which is also the root of the Fs.
+func Open(root string) (*Fs, error) {
+	f, err := os.Open(root)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return New(root, fi.ModTime(), f)
+}
+
+// decompress returns a reader for the data, after looking at the magic
+// bytes for gzip/bzip2/xz compression.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(6)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return xz.NewReader(br)
+	}
+	return br, nil
+}
+
+// New reads the tar archive from r, root is the path of the archive in the
+// Fs and mtime is used for it.
+func New(root string, mtime time.Time, r io.Reader) (*Fs, error) {
+	dr, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &Fs{
+		root:  root,
+		infos: make(map[string]os.FileInfo),
+		dirs:  make(map[string][]string),
+		links: make(map[string]string),
+	}
+	fs.infos[""] = &dirInfo{name: path.Base(root), mtime: mtime}
+
+	tr := tar.NewReader(dr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.Trim(path.Clean("/"+hdr.Name), "/")
+		if name == "" {
+			continue
+		}
+		fs.add(name, hdr.FileInfo())
+		if hdr.Typeflag == tar.TypeSymlink {
+			fs.links[name] = hdr.Linkname
+		}
+	}
+
+	for dir := range fs.dirs {
+		sort.Strings(fs.dirs[dir])
+	}
+	return fs, nil
+}
+
+// add the entry, and any parent dirs. that haven't been seen.
+func (fs *Fs) add(name string, fi os.FileInfo) {
+	if _, ok := fs.infos[name]; !ok {
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+		if _, ok := fs.infos[dir]; !ok {
+			fs.add(dir, &dirInfo{name: path.Base(dir), mtime: fi.ModTime()})
+		}
+		fs.dirs[dir] = append(fs.dirs[dir], path.Base(name))
+	}
+	fs.infos[name] = fi
+}
+
+// lookup converts the tree path to the archive path.
+func (fs *Fs) lookup(p string) (string, bool) {
+	rel, err := filepath.Rel(fs.root, p)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Stat returns the FileInfo from the tar header for the path.
+func (fs *Fs) Stat(p string) (os.FileInfo, error) {
+	if name, ok := fs.lookup(p); ok {
+		if fi, ok := fs.infos[name]; ok {
+			return fi, nil
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+}
+
+// ReadDir returns the names of the entries in the dir. path.
+func (fs *Fs) ReadDir(p string) ([]string, error) {
+	if name, ok := fs.lookup(p); ok {
+		if fi, ok := fs.infos[name]; ok && fi.IsDir() {
+			return append([]string(nil), fs.dirs[name]...), nil
+		}
+	}
+	return nil, &os.PathError{Op: "readdir", Path: p, Err: os.ErrNotExist}
+}
+
+// Readlink returns the target of a symlink in the archive.
+func (fs *Fs) Readlink(p string) (string, error) {
+	if name, ok := fs.lookup(p); ok {
+		if target, ok := fs.links[name]; ok {
+			return target, nil
+		}
+	}
+	return "", &os.PathError{Op: "readlink", Path: p, Err: os.ErrInvalid}
+}
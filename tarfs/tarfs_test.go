@@ -0,0 +1,60 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTarFs(t *testing.T) {
+	mtime := time.Date(2020, 10, 2, 1, 57, 13, 0, time.UTC)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range []*tar.Header{
+		{Name: "a/b/c.txt", Mode: 0644, Size: 3, ModTime: mtime, Typeflag: tar.TypeReg},
+		{Name: "a/d/", Mode: 0700, ModTime: mtime, Typeflag: tar.TypeDir},
+		{Name: "a/lnk", Linkname: "b/c.txt", Mode: 0777, ModTime: mtime, Typeflag: tar.TypeSymlink},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("abc"))
+		}
+	}
+	tw.Close()
+	gw.Close()
+
+	fs, err := New("x.tgz", mtime, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fs.ReadDir("x.tgz/a")
+	if err != nil || !reflect.DeepEqual(names, []string{"b", "d", "lnk"}) {
+		t.Errorf("ReadDir: got %v (%v)", names, err)
+	}
+	fi, err := fs.Stat("x.tgz/a/b/c.txt")
+	if err != nil || fi.Size() != 3 || fi.Mode().Perm() != 0644 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("Stat: got %v (%v)", fi, err)
+	}
+	if fi, err := fs.Stat("x.tgz/a/d"); err != nil || !fi.IsDir() || fi.Mode().Perm() != 0700 {
+		t.Errorf("Stat dir: got %v (%v)", fi, err)
+	}
+	if fi, err := fs.Stat("x.tgz"); err != nil || !fi.IsDir() || fi.Name() != "x.tgz" {
+		t.Errorf("Stat root: got %v (%v)", fi, err)
+	}
+	if target, err := fs.Readlink("x.tgz/a/lnk"); err != nil || target != "b/c.txt" {
+		t.Errorf("Readlink: got %v (%v)", target, err)
+	}
+	if _, err := fs.Stat("x.tgz/nope"); err == nil {
+		t.Errorf("Stat: expected error for missing file")
+	}
+	if _, err := fs.Stat("y.tgz/a"); err == nil {
+		t.Errorf("Stat: expected error for path outside the archive")
+	}
+}
@@ -0,0 +1,60 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateNode is the data Options.Template is executed with, for each node.
+type TemplateNode struct {
+	Path    string
+	Name    string
+	Depth   int
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+	// Indent is the tree structure, Display is the name as the default
+	// output shows it (quoted/colorized/joined/symlink target) and Props are
+	// the property columns.
+	Indent  string
+	Display string
+	Props   []string
+}
+
+// templateFormatter is the Formatter used when Options.Template is set.
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+// FormatLine executes the template for the node.
+func (t templateFormatter) FormatLine(node *Node, indent, name string,
+	props []string) string {
+	data := &TemplateNode{
+		Path:    node.path,
+		Name:    node.Name(),
+		Depth:   node.depth,
+		Size:    NodeSize(node),
+		Mode:    node.Mode(),
+		ModTime: node.ModTime(),
+		IsDir:   node.IsDir(),
+		Indent:  indent,
+		Display: name,
+		Props:   props,
+	}
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("%s [%s]", node.path, err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// FormatCutoff is the same as the TextFormatter.
+func (t templateFormatter) FormatCutoff(node *Node, indent string,
+	props []string, files int64) string {
+	return TextFormatter{}.FormatCutoff(node, indent, props, files)
+}
@@ -0,0 +1,205 @@
+package tree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TreeIgnoreName is the file in a dir. with the gitignore style patterns for
+// the entries under it, for Options.TreeIgnore.
+const TreeIgnoreName = ".treeignore"
+
+// gitIgnoreNames are the files in a dir. with the patterns for
+// Options.GitIgnore, the exclude file is only in the top dir. of a repo.
+var gitIgnoreNames = []string{".git/info/exclude", ".gitignore"}
+
+// ignoreRule is a compiled line from an ignore file.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	line    int
+}
+
+// ignoreRules are the rules from an ignore file for the dir. base, the
+// parent rules are from the files before it and for the dirs. above it.
+type ignoreRules struct {
+	parent *ignoreRules
+	base   string
+	file   string
+	rules  []ignoreRule
+}
+
+// compileIgnore converts a gitignore pattern to a regexp, matched against
+// the path relative to the dir. of the ignore file.
+func compileIgnore(pat string) (*regexp.Regexp, error) {
+	expr, err := globExpr(pat)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(expr)
+}
+
+// globExpr converts a gitignore style glob to a regexp, a glob without a /
+// matches the name at any depth and ** matches any number of dirs.
+func globExpr(pat string) (string, error) {
+	var re strings.Builder
+	// A pattern with a / (but not only at the end) is relative to the dir.
+	if !strings.Contains(pat, "/") {
+		re.WriteString("^(?:.*/)?")
+	} else {
+		re.WriteString("^")
+		pat = strings.TrimPrefix(pat, "/")
+	}
+	for i := 0; i < len(pat); i++ {
+		c := pat[i]
+		switch {
+		case strings.HasPrefix(pat[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case pat[i:] == "/**":
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pat[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pat[i+1:], ']')
+			if end == -1 {
+				return "", fmt.Errorf("missing ] in %q", pat)
+			}
+			class := pat[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pat):
+			i++
+			re.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return re.String(), nil
+}
+
+// loadIgnores returns the rules for the entries of the dir., which are the
+// parent rules if it doesn't have any ignore files. The .treeignore rules
+// are after the git ones, so they win. The GitExcludes and TreeIgnoreFile
+// are for the root.
+func loadIgnores(opts *Options, dir string, parent *ignoreRules, root bool) *ignoreRules {
+	irules := parent
+	if root {
+		var files []string
+		if opts.GitIgnore {
+			files = append(files, opts.GitExcludes)
+		}
+		if opts.TreeIgnore {
+			files = append(files, opts.TreeIgnoreFile)
+		}
+		for _, file := range files {
+			if file == "" {
+				continue
+			}
+			if f, err := os.Open(file); err == nil {
+				irules = parseIgnore(f, dir, file, irules)
+				f.Close()
+			}
+		}
+	}
+	ofs, ok := opts.Fs.(OpenFs)
+	if !ok {
+		return irules
+	}
+	var names []string
+	if opts.GitIgnore {
+		names = append(names, gitIgnoreNames...)
+	}
+	if opts.TreeIgnore {
+		names = append(names, TreeIgnoreName)
+	}
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		f, err := ofs.Open(file)
+		if err != nil {
+			continue
+		}
+		irules = parseIgnore(f, dir, file, irules)
+		f.Close()
+	}
+	return irules
+}
+
+// parseIgnore returns the rules from the ignore file, for the dir. base,
+// which are the parent rules if it doesn't have any.
+func parseIgnore(r io.Reader, base, file string, parent *ignoreRules) *ignoreRules {
+	irules := &ignoreRules{parent: parent, base: base, file: file}
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{line: num}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		re, err := compileIgnore(line)
+		if err != nil { // Like git, bad patterns are ignored
+			continue
+		}
+		rule.re = re
+		irules.rules = append(irules.rules, rule)
+	}
+	if len(irules.rules) == 0 {
+		return parent
+	}
+	return irules
+}
+
+// match returns why the path is ignored, or "" if it isn't. The last
+// matching rule wins and the rules from deeper dirs. override the parent.
+func (irules *ignoreRules) match(path string, isDir bool) string {
+	for ; irules != nil; irules = irules.parent {
+		rel, err := filepath.Rel(irules.base, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for i := len(irules.rules) - 1; i >= 0; i-- {
+			rule := irules.rules[i]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if !rule.re.MatchString(rel) {
+				continue
+			}
+			if rule.negate {
+				return ""
+			}
+			return fmt.Sprintf("%s:%d", irules.file, rule.line)
+		}
+	}
+	return ""
+}
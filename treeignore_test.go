@@ -0,0 +1,70 @@
+package tree
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTreeIgnore(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/.treeignore", []byte("# logs\n*.log\n!keep.log\nc/\n"), 0644, testTime).
+		AddFile("root/a/.treeignore", []byte("!z.log\n/r\n"), 0644, testTime).
+		AddFile("root/a/b/z.log", nil, 0644, testTime).
+		AddFile("root/a/b/r", nil, 0644, testTime).
+		AddFile("root/a/r", nil, 0644, testTime).
+		AddFile("root/c/w", nil, 0644, testTime).
+		AddFile("root/keep.log", nil, 0644, testTime).
+		AddFile("root/x.log", nil, 0644, testTime)
+
+	f, err := ioutil.TempFile("", "treeignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("b/\n")
+	f.Close()
+
+	opts := &Options{TreeIgnore: true}
+	testMapFs(t, mfs, []treeTest{
+		{"treeignore", opts, `
+root
+┣━ a
+┃ ┗━ b
+┃   ┣━ r
+┃   ┗━ z.log
+┗━ keep.log
+`, 0, 0},
+		{"ignore-file", &Options{TreeIgnore: true, TreeIgnoreFile: f.Name()}, `
+root
+┣━ a
+┗━ keep.log
+`, 0, 0},
+	})
+
+	why, err := Explain(opts, "root", "root/a/r")
+	if err != nil || why != "matching ignore file (root/a/.treeignore:2)" {
+		t.Errorf("explain: got %q (%v)", why, err)
+	}
+}
+
+func TestGitIgnore(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/.gitignore", []byte("node_modules/\nbuild\n"), 0644, testTime).
+		AddFile("root/.git/info/exclude", []byte("*.tmp\n"), 0644, testTime).
+		AddFile("root/.treeignore", []byte("!b.tmp\n"), 0644, testTime).
+		AddFile("root/node_modules/x/y", nil, 0644, testTime).
+		AddFile("root/src/build/o", nil, 0644, testTime).
+		AddFile("root/src/a.go", nil, 0644, testTime).
+		AddFile("root/src/a.tmp", nil, 0644, testTime).
+		AddFile("root/src/b.tmp", nil, 0644, testTime)
+
+	testMapFs(t, mfs, []treeTest{
+		{"gitignore", &Options{GitIgnore: true, TreeIgnore: true}, `
+root
+┗━ src
+  ┣━ a.go
+  ┗━ b.tmp
+`, 0, 0},
+	})
+}
@@ -0,0 +1,206 @@
+// Package treetest has helpers to test the rendering of trees, with the
+// fixtures built from a short spec and the output compared to golden strings.
+//
+// A spec has one entry per line, the parent dirs. are created as needed:
+//
+//	dir/            an empty dir.
+//	path            an empty file
+//	path = data     a file with the data, \n is a newline
+//	path*           an executable file (path* = data also works)
+//	path -> target  a symlink
+//	# comment
+package treetest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/james-antill/tree"
+)
+
+// Mtime is the modification time of all the entries in a MapFs, and the
+// files from Build.
+var Mtime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// Entry is a line from a spec.
+type Entry struct {
+	Path   string
+	Data   string
+	Target string // For symlinks
+	Dir    bool
+	Exec   bool
+}
+
+// Parse returns the entries in the spec.
+func Parse(spec string) ([]Entry, error) {
+	var ents []Entry
+	for num, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var ent Entry
+		if i := strings.Index(line, " -> "); i != -1 {
+			ent.Path, ent.Target = line[:i], strings.TrimSpace(line[i+4:])
+		} else if i := strings.Index(line, " = "); i != -1 {
+			ent.Path = line[:i]
+			ent.Data = strings.Replace(line[i+3:], `\n`, "\n", -1)
+		} else {
+			ent.Path = line
+		}
+		ent.Path = strings.TrimSpace(ent.Path)
+		switch {
+		case strings.HasSuffix(ent.Path, "/"):
+			ent.Dir = true
+		case strings.HasSuffix(ent.Path, "*"):
+			ent.Exec = true
+			ent.Path = strings.TrimSuffix(ent.Path, "*")
+		}
+		ent.Path = strings.TrimRight(ent.Path, "/")
+		if ent.Path == "" || filepath.IsAbs(ent.Path) ||
+			strings.HasPrefix(filepath.Clean(ent.Path), "..") {
+			return nil, fmt.Errorf("line %d: bad path %q", num+1, line)
+		}
+		if ent.Dir && (ent.Data != "" || ent.Target != "") {
+			return nil, fmt.Errorf("line %d: dirs. can't have data: %q", num+1, line)
+		}
+		ents = append(ents, ent)
+	}
+	return ents, nil
+}
+
+// mustParse is Parse, failing the test for a bad spec.
+func mustParse(tb testing.TB, spec string) []Entry {
+	tb.Helper()
+	ents, err := Parse(spec)
+	if err != nil {
+		tb.Fatalf("treetest: %v", err)
+	}
+	return ents
+}
+
+// MapFs returns a tree.MapFs with the entries in the spec, under root.
+func MapFs(tb testing.TB, root, spec string) *tree.MapFs {
+	tb.Helper()
+	mfs := tree.NewMapFs().AddDir(root, 0755, Mtime)
+	for _, ent := range mustParse(tb, spec) {
+		path := filepath.Join(root, ent.Path)
+		switch {
+		case ent.Dir:
+			mfs.AddDir(path, 0755, Mtime)
+		case ent.Target != "":
+			mfs.AddSymlink(path, ent.Target, Mtime)
+		case ent.Exec:
+			mfs.AddFile(path, []byte(ent.Data), 0755, Mtime)
+		default:
+			mfs.AddFile(path, []byte(ent.Data), 0644, Mtime)
+		}
+	}
+	return mfs
+}
+
+// Build creates a temp. dir. with the entries in the spec, which is removed
+// when the test is done, and returns its path. Use it with OSFs.
+func Build(tb testing.TB, spec string) string {
+	tb.Helper()
+	ents := mustParse(tb, spec)
+	root, err := ioutil.TempDir("", "treetest")
+	if err != nil {
+		tb.Fatalf("treetest: %v", err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(root) })
+
+	for _, ent := range ents {
+		path := filepath.Join(root, ent.Path)
+		if ent.Dir {
+			err = os.MkdirAll(path, 0755)
+		} else if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			switch {
+			case ent.Target != "":
+				err = os.Symlink(ent.Target, path)
+			case ent.Exec:
+				err = ioutil.WriteFile(path, []byte(ent.Data), 0755)
+			default:
+				err = ioutil.WriteFile(path, []byte(ent.Data), 0644)
+			}
+		}
+		if err == nil && ent.Target == "" {
+			err = os.Chtimes(path, Mtime, Mtime)
+		}
+		if err != nil {
+			tb.Fatalf("treetest: %v", err)
+		}
+	}
+	return root
+}
+
+// OSFs is a tree.Fs (and OpenFs) for the OS filesystem, like cmd/tree
+// without the archives.
+type OSFs struct{}
+
+// Stat returns the FileInfo for the path, symlinks aren't followed.
+func (OSFs) Stat(path string) (os.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+// ReadDir returns the names in the dir.
+func (OSFs) ReadDir(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+// Open the file, for the content types and ignore files.
+func (OSFs) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// Render visits and prints the tree at root with the options, and returns
+// the output. The OutFile of the options is only used during the call.
+func Render(opts *tree.Options, root string) string {
+	var buf bytes.Buffer
+	out := opts.OutFile
+	opts.OutFile = &buf
+	defer func() { opts.OutFile = out }()
+
+	inf := tree.New(root)
+	inf.Visit(opts)
+	inf.Print(opts)
+	return buf.String()
+}
+
+// Golden fails the test if the output isn't the expected one, showing the
+// first line that's different. A newline at the start of expected is
+// ignored, so it can be a raw string starting on the next line.
+func Golden(tb testing.TB, got, expected string) {
+	tb.Helper()
+	expected = strings.TrimPrefix(expected, "\n")
+	if got == expected {
+		return
+	}
+	glines := strings.Split(got, "\n")
+	elines := strings.Split(expected, "\n")
+	line := 0
+	for line < len(glines) && line < len(elines) && glines[line] == elines[line] {
+		line++
+	}
+	var gline, eline string
+	if line < len(glines) {
+		gline = glines[line]
+	}
+	if line < len(elines) {
+		eline = elines[line]
+	}
+	tb.Errorf("output differs at line %d:\n got: %q\nwant: %q\n\ngot:\n%s\nexpected:\n%s",
+		line+1, gline, eline, got, expected)
+}
@@ -0,0 +1,60 @@
+package treetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/james-antill/tree"
+)
+
+const spec = `
+# A small project
+src/main.go = package main\n
+src/util/
+bin/run*
+README -> src/main.go
+`
+
+func TestParse(t *testing.T) {
+	ents, err := Parse(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{
+		{Path: "src/main.go", Data: "package main\n"},
+		{Path: "src/util", Dir: true},
+		{Path: "bin/run", Exec: true},
+		{Path: "README", Target: "src/main.go"},
+	}
+	if len(ents) != len(expected) {
+		t.Fatalf("got %+v", ents)
+	}
+	for i := range ents {
+		if ents[i] != expected[i] {
+			t.Errorf("%d: got %+v expected %+v", i, ents[i], expected[i])
+		}
+	}
+	for _, bad := range []string{"/abs", "../up", "d/ = data"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	expected := `
+root
+┣━ README -> src/main.go
+┣━ bin
+┃ ┗━ run
+┗━ src
+  ┣━ main.go
+  ┗━ util
+`
+	opts := &tree.Options{Fs: MapFs(t, "root", spec)}
+	Golden(t, Render(opts, "root"), expected)
+
+	root := Build(t, spec)
+	opts = &tree.Options{Fs: OSFs{}}
+	Golden(t, strings.Replace(Render(opts, root), root, "root", 1), expected)
+}
@@ -0,0 +1,90 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Usage is the number of entries (inodes) and bytes used under a dir.
+type Usage struct {
+	Path    string
+	Entries int64
+	Bytes   int64
+}
+
+// usageSorts are the valid keys for TopUsage
+var usageSorts = []string{"entries", "size", "name"}
+
+// countEntries returns the number of entries under the node, including it.
+func countEntries(node *Node) int64 {
+	if node.dCount > 0 {
+		return node.dCount
+	}
+	num := int64(1)
+	for _, nnode := range node.nodes {
+		num += countEntries(nnode)
+	}
+	node.dCount = num
+	return num
+}
+
+// TopUsage returns the usage of each dir. directly under the roots, sorted
+// by the key: entries or size (largest first), or name.
+func TopUsage(sortBy string, roots ...*Node) ([]Usage, error) {
+	var found bool
+	for _, key := range usageSorts {
+		found = found || key == sortBy
+	}
+	if !found {
+		return nil, fmt.Errorf("usage sort '%s' not valid, should be one of: "+
+			"entries,size,name", sortBy)
+	}
+
+	var ret []Usage
+	for _, root := range roots {
+		for _, nnode := range root.nodes {
+			if !nnode.IsDir() {
+				continue
+			}
+			ret = append(ret, Usage{
+				Path:    nnode.path,
+				Entries: countEntries(nnode),
+				Bytes:   NodeSize(nnode),
+			})
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		switch sortBy {
+		case "entries":
+			if ret[i].Entries != ret[j].Entries {
+				return ret[i].Entries > ret[j].Entries
+			}
+		case "size":
+			if ret[i].Bytes != ret[j].Bytes {
+				return ret[i].Bytes > ret[j].Bytes
+			}
+		}
+		return ret[i].Path < ret[j].Path
+	})
+	return ret, nil
+}
+
+// printUsage prints the usage table, after the report.
+func printUsage(opts *Options, w io.Writer, usage []Usage) {
+	if len(usage) == 0 {
+		return
+	}
+	if opts.ReverSort {
+		for i, j := 0, len(usage)-1; i < j; i, j = i+1, j-1 {
+			usage[i], usage[j] = usage[j], usage[i]
+		}
+	}
+	size := FormatSize(opts, 0)
+	fmt.Fprintf(w, "\n%10s %*s  %s\n", "entries", len(size), "size", "path")
+	for _, u := range usage {
+		fmt.Fprintf(w, "%10d %s  %s\n", u.Entries, FormatSize(opts, u.Bytes),
+			u.Path)
+	}
+}
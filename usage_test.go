@@ -0,0 +1,28 @@
+package tree
+
+import "testing"
+
+func TestTopUsage(t *testing.T) {
+	mfs := NewMapFs().
+		AddFile("root/a/b", make([]byte, 100), 0644, testTime).
+		AddFile("root/c/d", []byte("x"), 0644, testTime).
+		AddFile("root/c/e/f", []byte("y"), 0644, testTime).
+		AddFile("root/g", make([]byte, 1000), 0644, testTime)
+
+	inf := visitMapFs(mfs, &Options{})
+	usage, err := TopUsage("entries", inf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Usage{{"root/c", 4, 2}, {"root/a", 2, 100}}
+	if len(usage) != 2 || usage[0] != expected[0] || usage[1] != expected[1] {
+		t.Errorf("entries: got %+v expected %+v", usage, expected)
+	}
+	usage, _ = TopUsage("size", inf)
+	if len(usage) != 2 || usage[0].Path != "root/a" {
+		t.Errorf("size: got %+v", usage)
+	}
+	if _, err := TopUsage("inodes", inf); err == nil {
+		t.Errorf("expected an error for an unknown sort")
+	}
+}
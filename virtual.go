@@ -0,0 +1,57 @@
+package tree
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// virtualFI is the FileInfo for virtual nodes
+type virtualFI struct {
+	name  string
+	size  int64
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func (v *virtualFI) Name() string       { return v.name }
+func (v *virtualFI) Size() int64        { return v.size }
+func (v *virtualFI) Mode() os.FileMode  { return v.mode }
+func (v *virtualFI) ModTime() time.Time { return v.mtime }
+func (v *virtualFI) IsDir() bool        { return v.mode.IsDir() }
+func (v *virtualFI) Sys() interface{}   { return nil }
+
+// VirtualInfo returns a FileInfo for a virtual node, use os.ModeDir in the
+// mode for a virtual dir.
+func VirtualInfo(name string, size int64, mode os.FileMode,
+	mtime time.Time) os.FileInfo {
+	return &virtualFI{name: name, size: size, mode: mode, mtime: mtime}
+}
+
+// NewVirtual creates a node that doesn't exist in the Fs, for
+// Options.Inject to add to a directory. Virtual dirs. can have children.
+func NewVirtual(fi os.FileInfo, children ...*Node) *Node {
+	return &Node{FileInfo: fi, nodes: children, virtual: true}
+}
+
+// setupVirtual sets the path/depth of the virtual node (and children) under
+// the parent, and returns the dirs. and files it adds.
+func (node *Node) setupVirtual(opts *Options, parent *Node) (dirs, files int) {
+	node.path = filepath.Join(parent.path, node.Name())
+	node.depth = parent.depth + 1
+	if opts.NDJSON {
+		node.emitNDJSON(opts)
+	}
+	if !node.IsDir() {
+		return 0, 1
+	}
+	dirs++
+	for _, nnode := range node.nodes {
+		d, f := nnode.setupVirtual(opts, node)
+		dirs, files = dirs+d, files+f
+	}
+	if opts.NDJSON { // Streamed, so don't keep the nodes
+		node.nodes = nil
+	}
+	return dirs, files
+}
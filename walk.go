@@ -0,0 +1,106 @@
+package tree
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Path returns the path of the node, as given to the Fs.
+func (node *Node) Path() string {
+	return node.path
+}
+
+// Depth returns the depth of the node, the root is 0.
+func (node *Node) Depth() int {
+	return node.depth
+}
+
+// relPath returns the path of the node from the root, the last depth parts
+// of the path.
+func (node *Node) relPath() string {
+	if node.depth == 0 {
+		return node.Name()
+	}
+	parts := strings.Split(node.path, string(filepath.Separator))
+	if len(parts) > node.depth {
+		parts = parts[len(parts)-node.depth:]
+	}
+	return filepath.Join(parts...)
+}
+
+// Err returns the error from visiting the node, if any.
+func (node *Node) Err() error {
+	return node.err
+}
+
+// NodeError is an error from visiting the node at the path.
+type NodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Errors returns the errors for the node and everything under it, in the
+// order they were found.
+func (node *Node) Errors() []error {
+	var ret []error
+	node.Walk(func(n *Node) error {
+		if n.err != nil {
+			ret = append(ret, &NodeError{Path: n.path, Err: n.err})
+		}
+		return nil
+	})
+	return ret
+}
+
+// Children returns the nodes visited under the node, in the order they were
+// found.
+func (node *Node) Children() Nodes {
+	return node.nodes
+}
+
+// IsVirtual returns if the node was added by Options.Inject, and so isn't
+// in the Fs.
+func (node *Node) IsVirtual() bool {
+	return node.virtual
+}
+
+// Walk calls fn for the node and everything visited under it, depth first
+// (in the order they were found, see SortedChildren). If fn returns SkipNode
+// the children of that node aren't walked, any other error stops the walk and
+// is returned.
+func (node *Node) Walk(fn func(n *Node) error) error {
+	return node.walk(nil, fn)
+}
+
+// WalkSorted is Walk, with the children sorted like the output for opts.
+func (node *Node) WalkSorted(opts *Options, fn func(n *Node) error) error {
+	return node.walk(opts, fn)
+}
+
+// SortedChildren returns the children sorted like the output for opts.
+func (node *Node) SortedChildren(opts *Options) Nodes {
+	return node.sortedNodes(opts)
+}
+
+func (node *Node) walk(opts *Options, fn func(n *Node) error) error {
+	if err := fn(node); err == SkipNode {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	nodes := node.nodes
+	if opts != nil {
+		nodes = node.sortedNodes(opts)
+	}
+	for _, nnode := range nodes {
+		if err := nnode.walk(opts, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package tree
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Where is a compiled filter expression, for Options.Where. Eg.
+//
+//	size > 10MB && ext == ".log" && mtime < now-30d
+//
+// The fields are: name, path, ext, type (file, dir, symlink ...), size,
+// mtime and depth. Numbers can have size units (K, M, Gi ...) or duration
+// units (s, m, h, d, w). Strings are quoted, and =~ matches a regexp.
+// Comparisons can be combined with &&, || and ! and grouped with ().
+type Where struct {
+	expr string
+	eval whereFn
+}
+
+// whereVal is the value of a field or literal, numbers are int64 and times
+// are in nanoseconds so that now-30d works.
+type whereVal struct {
+	num   int64
+	str   string
+	isStr bool
+}
+
+type whereFn func(node *Node) whereVal
+
+// whereFields are the values for the node, of the field names.
+var whereFields = map[string]whereFn{
+	"name": func(node *Node) whereVal {
+		return whereVal{str: node.Name(), isStr: true}
+	},
+	"path": func(node *Node) whereVal {
+		return whereVal{str: node.path, isStr: true}
+	},
+	"ext": func(node *Node) whereVal {
+		return whereVal{str: filepath.Ext(node.Name()), isStr: true}
+	},
+	"type": func(node *Node) whereVal {
+		return whereVal{str: nodeType(node), isStr: true}
+	},
+	"size":  func(node *Node) whereVal { return whereVal{num: NodeSize(node)} },
+	"mtime": func(node *Node) whereVal { return whereVal{num: node.ModTime().UnixNano()} },
+	"depth": func(node *Node) whereVal { return whereVal{num: int64(node.depth)} },
+}
+
+// whereDurations are the units for durations, lower case so they are
+// different from the size units.
+var whereDurations = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// CompileWhere parses the filter expression, "now" is the time it's called.
+func CompileWhere(expr string) (*Where, error) {
+	toks, err := whereTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{toks: toks, now: time.Now().UnixNano()}
+	eval, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("where: unexpected %q", p.toks[p.pos])
+	}
+	return &Where{expr: expr, eval: eval}, nil
+}
+
+// String returns the expression the Where was compiled from.
+func (w *Where) String() string {
+	return w.expr
+}
+
+// Match returns if the node matches the expression.
+func (w *Where) Match(node *Node) bool {
+	return w.eval(node).num != 0
+}
+
+// whereTokens splits the expression into tokens, strings keep the quotes.
+func whereTokens(expr string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("where: unterminated string %s", expr[i:])
+			}
+			toks = append(toks, expr[i:end+1])
+			i = end + 1
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '.' || c == '_':
+			end := i + 1
+			for ; end < len(expr); end++ {
+				c := rune(expr[end])
+				if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '.' && c != '_' {
+					break
+				}
+			}
+			toks = append(toks, expr[i:end])
+			i = end
+		default:
+			tok := expr[i : i+1]
+			for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "=~"} {
+				if strings.HasPrefix(expr[i:], op) {
+					tok = op
+				}
+			}
+			if !strings.Contains("()!<>+-", tok) && len(tok) == 1 {
+				return nil, fmt.Errorf("where: unexpected %q", tok)
+			}
+			toks = append(toks, tok)
+			i += len(tok)
+		}
+	}
+	return toks, nil
+}
+
+// whereParser is a recursive descent parser for the expression, which
+// returns the functions to evaluate each part.
+type whereParser struct {
+	toks []string
+	pos  int
+	now  int64
+}
+
+func (p *whereParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos]
+}
+
+func (p *whereParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func whereBool(b bool) whereVal {
+	if b {
+		return whereVal{num: 1}
+	}
+	return whereVal{}
+}
+
+func (p *whereParser) or() (whereFn, error) {
+	lhs, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		rhs, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(node *Node) whereVal {
+			return whereBool(l(node).num != 0 || rhs(node).num != 0)
+		}
+	}
+	return lhs, nil
+}
+
+func (p *whereParser) and() (whereFn, error) {
+	lhs, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		rhs, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(node *Node) whereVal {
+			return whereBool(l(node).num != 0 && rhs(node).num != 0)
+		}
+	}
+	return lhs, nil
+}
+
+func (p *whereParser) not() (whereFn, error) {
+	if p.peek() == "!" {
+		p.next()
+		val, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(node *Node) whereVal { return whereBool(val(node).num == 0) }, nil
+	}
+	if p.peek() == "(" {
+		p.next()
+		val, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("where: missing )")
+		}
+		return val, nil
+	}
+	return p.cmp()
+}
+
+func (p *whereParser) cmp() (whereFn, error) {
+	lhs, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if op == "=~" {
+		tok := p.next()
+		if !strings.HasPrefix(tok, `"`) {
+			return nil, fmt.Errorf("where: =~ needs a string, not %q", tok)
+		}
+		str, _ := strconv.Unquote(tok)
+		re, err := regexp.Compile(str)
+		if err != nil {
+			return nil, fmt.Errorf("where: %v", err)
+		}
+		return func(node *Node) whereVal {
+			return whereBool(re.MatchString(lhs(node).str))
+		}, nil
+	}
+
+	var test func(c int) bool
+	switch op {
+	case "==":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	case ">=":
+		test = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("where: expected a comparison, not %q", op)
+	}
+	rhs, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	return func(node *Node) whereVal {
+		l, r := lhs(node), rhs(node)
+		if l.isStr || r.isStr {
+			return whereBool(test(strings.Compare(l.str, r.str)))
+		}
+		switch {
+		case l.num < r.num:
+			return whereBool(test(-1))
+		case l.num > r.num:
+			return whereBool(test(1))
+		}
+		return whereBool(test(0))
+	}, nil
+}
+
+func (p *whereParser) sum() (whereFn, error) {
+	lhs, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		sign := int64(1)
+		if p.next() == "-" {
+			sign = -1
+		}
+		rhs, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		l := lhs
+		lhs = func(node *Node) whereVal {
+			return whereVal{num: l(node).num + sign*rhs(node).num}
+		}
+	}
+	return lhs, nil
+}
+
+func (p *whereParser) value() (whereFn, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("where: unexpected end of expression")
+	case strings.HasPrefix(tok, `"`):
+		str, err := strconv.Unquote(tok)
+		if err != nil {
+			return nil, fmt.Errorf("where: bad string %s", tok)
+		}
+		return func(*Node) whereVal { return whereVal{str: str, isStr: true} }, nil
+	case tok == "now":
+		now := p.now
+		return func(*Node) whereVal { return whereVal{num: now} }, nil
+	case whereFields[tok] != nil:
+		return whereFields[tok], nil
+	case unicode.IsDigit(rune(tok[0])):
+		num, err := whereNumber(tok)
+		if err != nil {
+			return nil, err
+		}
+		return func(*Node) whereVal { return whereVal{num: num} }, nil
+	}
+	return nil, fmt.Errorf("where: unknown field %q", tok)
+}
+
+// whereNumber converts a number with a size or duration unit.
+func whereNumber(tok string) (int64, error) {
+	unit := strings.TrimLeftFunc(tok, func(c rune) bool {
+		return unicode.IsDigit(c) || c == '.'
+	})
+	if dur, ok := whereDurations[unit]; ok {
+		num, err := strconv.ParseFloat(strings.TrimSuffix(tok, unit), 64)
+		if err != nil {
+			return 0, fmt.Errorf("where: bad duration %q", tok)
+		}
+		return int64(num * float64(dur)), nil
+	}
+	num, err := ParseSize(tok)
+	if err != nil {
+		return 0, fmt.Errorf("where: bad number %q", tok)
+	}
+	return num, nil
+}
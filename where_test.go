@@ -0,0 +1,43 @@
+package tree
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWhere(t *testing.T) {
+	mtime := time.Now().Add(-48 * time.Hour)
+	node := &Node{
+		FileInfo: VirtualInfo("app.log", 20*MB, 0644, mtime),
+		path:     "var/app.log",
+		depth:    1,
+	}
+	data := []struct {
+		expr string
+		res  bool
+	}{
+		{`size > 10MB && ext == ".log"`, true},
+		{`size > 10MB && ext == ".txt"`, false},
+		{`mtime < now-1d`, true},
+		{`mtime < now-3d`, false},
+		{`!(depth > 0) || name =~ "^app\\."`, true},
+		{`type == "file" && path != "var/app.log"`, false},
+		{`size >= 20M && size <= 20000000`, true},
+	}
+	for i := range data {
+		w, err := CompileWhere(data[i].expr)
+		if err != nil {
+			t.Errorf("data %v: unexpected error: %v", i, err)
+			continue
+		}
+		if res := w.Match(node); res != data[i].res {
+			t.Errorf("data not equal: %v: got %v expected %v", i, res, data[i].res)
+		}
+	}
+
+	for _, expr := range []string{"", "size >", "foo == 1", `name =~ 1`, "(size > 1", "size = 1"} {
+		if _, err := CompileWhere(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+}
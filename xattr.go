@@ -0,0 +1,101 @@
+package tree
+
+import "strings"
+
+// XattrFs is an optional interface for a Fs, to list the names of the
+// extended attributes of the path (not following symlinks). Otherwise the
+// OS is asked, on Linux, macOS, FreeBSD and NetBSD.
+type XattrFs interface {
+	Listxattr(path string) ([]string, error)
+}
+
+// XattrValueFs is an optional interface for a XattrFs, to read the value of
+// an extended attribute of the path (not following symlinks). A XattrFs
+// without it has no values, otherwise the OS is asked.
+type XattrValueFs interface {
+	Getxattr(path, name string) ([]byte, error)
+}
+
+// xattrs returns the names of the extended attributes of the node, nil if
+// there aren't any or they can't be read.
+func xattrs(opts *Options, node *Node) []string {
+	if node.virtual {
+		return nil
+	}
+	var names []string
+	if xfs, ok := opts.Fs.(XattrFs); ok {
+		names, _ = xfs.Listxattr(node.path)
+	} else {
+		names, _ = listXattrs(node.path)
+	}
+	return names
+}
+
+// selinuxXattr is the extended attribute with the SELinux context
+const selinuxXattr = "security.selinux"
+
+// securityContext returns the SELinux context of the node, for
+// Options.Context, or ? if it doesn't have one like ls -Z.
+func securityContext(opts *Options, node *Node) string {
+	if node.virtual {
+		return "?"
+	}
+	var val []byte
+	if vfs, ok := opts.Fs.(XattrValueFs); ok {
+		val, _ = vfs.Getxattr(node.path, selinuxXattr)
+	} else if _, ok := opts.Fs.(XattrFs); !ok {
+		val, _ = getXattr(node.path, selinuxXattr)
+	}
+	if ctx := strings.TrimRight(string(val), "\x00"); ctx != "" {
+		return ctx
+	}
+	return "?"
+}
+
+// aclXattrs are the extended attributes that Linux stores the POSIX ACLs in,
+// they are only there if the ACL has more than the mode bits.
+var aclXattrs = map[string]bool{
+	"system.posix_acl_access":  true,
+	"system.posix_acl_default": true,
+}
+
+// checkXattrs reads the names of the extended attributes of the node, if
+// the ACL or xattr marks need them. It's done once while visiting, as both
+// passes of printing use them.
+func (node *Node) checkXattrs(opts *Options) {
+	if opts.FileMode || opts.Xattrs || opts.XattrNames {
+		node.xattrs = xattrs(opts, node)
+	}
+}
+
+// hasACL returns true if the node has a POSIX ACL, for the + after the mode
+// like ls -l. They are only found on Linux.
+func hasACL(node *Node) bool {
+	for _, name := range node.xattrs {
+		if aclXattrs[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// xattrMark returns the mark for the mode column, @ if the node has extended
+// attributes like BSD ls, otherwise a space to keep the column aligned. The
+// ACLs aren't counted, they have their own + mark.
+func xattrMark(node *Node) string {
+	for _, name := range node.xattrs {
+		if !aclXattrs[name] {
+			return "@"
+		}
+	}
+	return " "
+}
+
+// xattrText returns the annotation listing the extended attributes of the
+// node, for Options.XattrNames, or "".
+func xattrText(node *Node) string {
+	if len(node.xattrs) == 0 {
+		return ""
+	}
+	return " [xattrs: " + strings.Join(node.xattrs, ", ") + "]"
+}
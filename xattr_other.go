@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+// +build !linux,!darwin,!freebsd,!netbsd
+
+package tree
+
+// listXattrs returns the names of the extended attributes of the path, they
+// aren't read on this OS.
+func listXattrs(path string) ([]string, error) {
+	return nil, nil
+}
+
+// getXattr returns the value of the extended attribute of the path, they
+// aren't read on this OS.
+func getXattr(path, name string) ([]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+//go:build linux || darwin || freebsd || netbsd
+// +build linux darwin freebsd netbsd
+
+package tree
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// listXattrs returns the names of the extended attributes of the path, from
+// llistxattr(2) (or the extattr calls on the BSDs).
+func listXattrs(path string) ([]string, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Llistxattr(path, buf)
+		switch err {
+		case nil:
+		case unix.ERANGE: // The buffer is too small for the names
+			buf = make([]byte, len(buf)*4)
+			continue
+		case unix.ENOTSUP:
+			return nil, nil
+		default:
+			return nil, err
+		}
+		var names []string
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+// getXattr returns the value of the extended attribute of the path, from
+// lgetxattr(2) (or the extattr calls on the BSDs).
+func getXattr(path, name string) ([]byte, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Lgetxattr(path, name, buf)
+		switch err {
+		case nil:
+			return buf[:n], nil
+		case unix.ERANGE: // The buffer is too small for the value
+			buf = make([]byte, len(buf)*4)
+		default: // Like ENODATA, ENOATTR or ENOTSUP
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package zipfs is a tree.Fs for the contents of a zip archive (including
+// .jar and .whl files), so it can be shown without extracting it.
+package zipfs
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fs is a tree.Fs for a zip archive, paths are under the root given to
+// Open/New.
+type Fs struct {
+	root  string
+	infos map[string]os.FileInfo
+	dirs  map[string][]string
+	files map[string]*zip.File
+	links map[string]string
+
+	closer io.Closer
+}
+
+// dirInfo is the FileInfo for dirs. that aren't in the archive, but are
+// needed for the entries in it (and the root).
+type dirInfo struct {
+	name  string
+	mtime time.Time
+}
+
+func (d *dirInfo) Name() string       { return d.name }
+func (d *dirInfo) Size() int64        { return 0 }
+func (d *dirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d *dirInfo) ModTime() time.Time { return d.mtime }
+func (d *dirInfo) IsDir() bool        { return true }
+func (d *dirInfo) Sys() interface{}   { return nil }
+
+// Open reads the central directory of the zip archive at the path, which is
+// also the root of the Fs. Close should be called when done.
+func Open(root string) (*Fs, error) {
+	zr, err := zip.OpenReader(root)
+	if err != nil {
+		return nil, err
+	}
+	var mtime time.Time
+	if fi, err := os.Stat(root); err == nil {
+		mtime = fi.ModTime()
+	}
+
+	fs := New(root, mtime, &zr.Reader)
+	fs.closer = zr
+	return fs, nil
+}
+
+// New creates the Fs from the zip.Reader, root is the path of the archive in
+// the Fs and mtime is used for it.
+func New(root string, mtime time.Time, zr *zip.Reader) *Fs {
+	fs := &Fs{
+		root:  root,
+		infos: make(map[string]os.FileInfo),
+		dirs:  make(map[string][]string),
+		files: make(map[string]*zip.File),
+		links: make(map[string]string),
+	}
+	fs.infos[""] = &dirInfo{name: path.Base(root), mtime: mtime}
+
+	for _, zf := range zr.File {
+		name := strings.Trim(path.Clean("/"+zf.Name), "/")
+		if name == "" {
+			continue
+		}
+		fi := zf.FileInfo()
+		fs.add(name, fi)
+		if fi.IsDir() {
+			continue
+		}
+		fs.files[name] = zf
+		if fi.Mode()&os.ModeSymlink != 0 { // The target is the content
+			if target, err := readAll(zf); err == nil {
+				fs.links[name] = target
+			}
+		}
+	}
+
+	for dir := range fs.dirs {
+		sort.Strings(fs.dirs[dir])
+	}
+	return fs
+}
+
+// readAll returns the content of the zip file
+func readAll(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	return string(data), err
+}
+
+// add the entry, and any parent dirs. that haven't been seen.
+func (fs *Fs) add(name string, fi os.FileInfo) {
+	if _, ok := fs.infos[name]; !ok {
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+		if _, ok := fs.infos[dir]; !ok {
+			fs.add(dir, &dirInfo{name: path.Base(dir), mtime: fi.ModTime()})
+		}
+		fs.dirs[dir] = append(fs.dirs[dir], path.Base(name))
+	}
+	fs.infos[name] = fi
+}
+
+// lookup converts the tree path to the archive path.
+func (fs *Fs) lookup(p string) (string, bool) {
+	rel, err := filepath.Rel(fs.root, p)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Stat returns the FileInfo from the central directory for the path.
+func (fs *Fs) Stat(p string) (os.FileInfo, error) {
+	if name, ok := fs.lookup(p); ok {
+		if fi, ok := fs.infos[name]; ok {
+			return fi, nil
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+}
+
+// ReadDir returns the names of the entries in the dir. path.
+func (fs *Fs) ReadDir(p string) ([]string, error) {
+	if name, ok := fs.lookup(p); ok {
+		if fi, ok := fs.infos[name]; ok && fi.IsDir() {
+			return append([]string(nil), fs.dirs[name]...), nil
+		}
+	}
+	return nil, &os.PathError{Op: "readdir", Path: p, Err: os.ErrNotExist}
+}
+
+// Readlink returns the target of a symlink in the archive.
+func (fs *Fs) Readlink(p string) (string, error) {
+	if name, ok := fs.lookup(p); ok {
+		if target, ok := fs.links[name]; ok {
+			return target, nil
+		}
+	}
+	return "", &os.PathError{Op: "readlink", Path: p, Err: os.ErrInvalid}
+}
+
+// Open returns the uncompressed content of a file in the archive.
+func (fs *Fs) Open(p string) (io.ReadCloser, error) {
+	if name, ok := fs.lookup(p); ok {
+		if zf, ok := fs.files[name]; ok {
+			return zf.Open()
+		}
+	}
+	return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+}
+
+// Close closes the archive file, if it was opened by Open.
+func (fs *Fs) Close() error {
+	if fs.closer == nil {
+		return nil
+	}
+	return fs.closer.Close()
+}
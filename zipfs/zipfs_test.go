@@ -0,0 +1,65 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestZipFs(t *testing.T) {
+	mtime := time.Date(2020, 10, 2, 1, 56, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, ent := range []struct {
+		name, data string
+		mode       os.FileMode
+	}{
+		{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\n", 0644},
+		{"a/b/c.class", "\xca\xfe\xba\xbe", 0644},
+		{"a/lnk", "b/c.class", os.ModeSymlink | 0777},
+	} {
+		hdr := &zip.FileHeader{Name: ent.name, Method: zip.Deflate}
+		hdr.Modified = mtime
+		hdr.SetMode(ent.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(ent.data))
+	}
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := New("x.jar", mtime, zr)
+
+	names, err := fs.ReadDir("x.jar")
+	if err != nil || !reflect.DeepEqual(names, []string{"META-INF", "a"}) {
+		t.Errorf("ReadDir: got %v (%v)", names, err)
+	}
+	fi, err := fs.Stat("x.jar/a/b/c.class")
+	if err != nil || fi.Size() != 4 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("Stat: got %v (%v)", fi, err)
+	}
+	if fi, err := fs.Stat("x.jar/a/b"); err != nil || !fi.IsDir() {
+		t.Errorf("Stat dir: got %v (%v)", fi, err)
+	}
+	if target, err := fs.Readlink("x.jar/a/lnk"); err != nil || target != "b/c.class" {
+		t.Errorf("Readlink: got %v (%v)", target, err)
+	}
+	rc, err := fs.Open("x.jar/META-INF/MANIFEST.MF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if string(data) != "Manifest-Version: 1.0\n" {
+		t.Errorf("Open: got %q", data)
+	}
+}